@@ -20,15 +20,53 @@ import "github.com/kelseyhightower/envconfig"
 
 // Configuration specifies the Solo configuration
 type Configuration struct {
-	WorkmanagerNotificationsBindAddr string `envconfig:"solo_workmanager_notifications_bind_addr" required:"true"`
-	GatewayInsecureBindAddr          string `envconfig:"solo_gateway_insecure_bind_addr"`
-	GatewayPassword                  string `envconfig:"solo_gateway_password" required:"true"`
-	ShareDifficulty                  uint64 `envconfig:"solo_share_difficulty" default:"4000000000"`
-	NodeHTTPRPC                      string `envconfig:"solo_node_http_rpc" default:"http://127.0.0.1:8545"`
-	DBPath                           string `envconfig:"solo_db_path" default:"./solo_db"`
-	LogLevel                         string `envconfig:"solo_log_level" default:"info"`
-	BlockConfirmationsRequired       uint64 `envconfig:"solo_block_confirmations_required" default:"60"`
-	WebServerBind                    string `envconfig:"solo_webserver_bind" default:"127.0.0.1:8085"`
+	WorkmanagerNotificationsBindAddr   string   `envconfig:"solo_workmanager_notifications_bind_addr"`
+	WorkmanagerNotificationsToken      string   `envconfig:"solo_workmanager_notifications_token"`
+	WorkmanagerNotificationsHMACSecret string   `envconfig:"solo_workmanager_notifications_hmac_secret"`
+	WorkmanagerNotificationsAllowedIPs []string `envconfig:"solo_workmanager_notifications_allowed_ips"`
+	WorkmanagerPollIntervalMs          uint64   `envconfig:"solo_workmanager_poll_interval_ms" default:"0"`
+	WorkmanagerWatchdogBlockTimes      uint64   `envconfig:"solo_workmanager_watchdog_block_times" default:"5"`
+	BlockTimeSecs                      uint64   `envconfig:"solo_block_time_secs" default:"13"`
+	GatewayInsecureBindAddr            string   `envconfig:"solo_gateway_insecure_bind_addr"`
+	GatewaySecureBindAddr              string   `envconfig:"solo_gateway_secure_bind_addr"`
+	GatewayTLSCertFile                 string   `envconfig:"solo_gateway_tls_cert_file"`
+	GatewayTLSKeyFile                  string   `envconfig:"solo_gateway_tls_key_file"`
+	GatewayPassword                    string   `envconfig:"solo_gateway_password"`
+	GatewayMaxConnections              uint64   `envconfig:"solo_gateway_max_connections" default:"10000"`
+	GatewayMaxConnectionsPerIP         uint64   `envconfig:"solo_gateway_max_connections_per_ip" default:"0"`
+	GatewayIdleTimeoutSecs             uint64   `envconfig:"solo_gateway_idle_timeout_secs" default:"600"`
+	GatewayKeepAliveSecs               uint64   `envconfig:"solo_gateway_keepalive_secs" default:"60"`
+	GatewayShutdownGraceSecs           uint64   `envconfig:"solo_gateway_shutdown_grace_secs" default:"10"`
+	GatewayProxyProtocol               bool     `envconfig:"solo_gateway_proxy_protocol" default:"false"`
+	GatewayProxyProtocolTrustedIPs     []string `envconfig:"solo_gateway_proxy_protocol_trusted_ips"`
+	BanEnabled                         bool     `envconfig:"solo_ban_enabled" default:"true"`
+	BanMaxFailedLogins                 uint64   `envconfig:"solo_ban_max_failed_logins" default:"5"`
+	BanMaxMalformedRequests            uint64   `envconfig:"solo_ban_max_malformed_requests" default:"10"`
+	BanMaxInvalidShareRatio            float64  `envconfig:"solo_ban_max_invalid_share_ratio" default:"0.5"`
+	BanMinSharesForRatio               uint64   `envconfig:"solo_ban_min_shares_for_ratio" default:"20"`
+	BanWindowSecs                      uint64   `envconfig:"solo_ban_window_secs" default:"600"`
+	BanDurationSecs                    uint64   `envconfig:"solo_ban_duration_secs" default:"300"`
+	BanMaxDurationSecs                 uint64   `envconfig:"solo_ban_max_duration_secs" default:"86400"`
+	BanExemptIPs                       []string `envconfig:"solo_ban_exempt_ips"`
+	ShareDifficulty                    uint64   `envconfig:"solo_share_difficulty" default:"4000000000"`
+	VardiffEnabled                     bool     `envconfig:"solo_vardiff_enabled" default:"false"`
+	VardiffTargetSharesPerMinute       float64  `envconfig:"solo_vardiff_target_shares_per_minute" default:"6"`
+	VardiffMinDifficulty               uint64   `envconfig:"solo_vardiff_min_difficulty" default:"1000000000"`
+	VardiffMaxDifficulty               uint64   `envconfig:"solo_vardiff_max_difficulty" default:"1000000000000"`
+	VardiffRetargetIntervalSecs        uint64   `envconfig:"solo_vardiff_retarget_interval_secs" default:"60"`
+	NodeHTTPRPC                        []string `envconfig:"solo_node_http_rpc" default:"http://127.0.0.1:8545"`
+	NodeWSRPC                          []string `envconfig:"solo_node_ws_rpc"`
+	NodeHealthCheckIntervalSecs        uint64   `envconfig:"solo_node_health_check_interval_secs" default:"5"`
+	NodeMinPeers                       uint64   `envconfig:"solo_node_min_peers" default:"1"`
+	NodeMaxBlockLag                    uint64   `envconfig:"solo_node_max_block_lag" default:"3"`
+	DBPath                             string   `envconfig:"solo_db_path" default:"./solo_db"`
+	StatsCollectionPeriodSecs          int64    `envconfig:"solo_stats_collection_period_secs" default:"600"`
+	StatsRetentionSecs                 int64    `envconfig:"solo_stats_retention_secs" default:"86400"`
+	LogLevel                           string   `envconfig:"solo_log_level" default:"info"`
+	BlockConfirmationsRequired         uint64   `envconfig:"solo_block_confirmations_required" default:"60"`
+	WebServerBind                      string   `envconfig:"solo_webserver_bind" default:"127.0.0.1:8085"`
+	WebServerAdminToken                string   `envconfig:"solo_webserver_admin_token"`
+	WebServerCORSAllowedOrigins        []string `envconfig:"solo_webserver_cors_allowed_origins"`
 }
 
 // GetConfig parses the environment variables
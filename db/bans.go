@@ -0,0 +1,60 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Ban represents an interface for an IP ban DB object
+type Ban struct {
+	IPAddress string `msgpack:"ip_address" json:"ip"`
+	Reason    string `msgpack:"reason" json:"reason"`
+	Offenses  uint64 `msgpack:"offenses" json:"offenses"` // Used to escalate the ban duration
+	BannedAt  int64  `msgpack:"banned_at" json:"bannedAt"`
+	ExpiresAt int64  `msgpack:"expires_at" json:"expiresAt"`
+}
+
+// WriteBan writes (or replaces) the IP ban to the database
+func (db *Database) WriteBan(ban Ban) error {
+	data, _ := msgpack.Marshal(ban)
+	return db.DB.Put([]byte(BanPrefix+ban.IPAddress), data, nil)
+}
+
+// GetBans returns all IP bans (including the expired ones) from the database
+func (db *Database) GetBans() ([]Ban, error) {
+	var bans []Ban
+	iter := db.DB.NewIterator(util.BytesPrefix([]byte(BanPrefix)), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		var ban Ban
+		if err := msgpack.Unmarshal(iter.Value(), &ban); err != nil {
+			return nil, errors.Wrap(err, "Database is corrupted")
+		}
+		bans = append(bans, ban)
+	}
+
+	return bans, iter.Error()
+}
+
+// DeleteBan removes the IP ban from the database
+func (db *Database) DeleteBan(ip string) error {
+	return db.DB.Delete([]byte(BanPrefix+ip), nil)
+}
@@ -0,0 +1,228 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// RoundBestShares represents the best shares of a round
+type RoundBestShares struct {
+	RoundStart int64       `json:"roundStart"`
+	Current    bool        `json:"current"`
+	Best       BestShare   `json:"best"`
+	Workers    []BestShare `json:"workers"` // The best share of every worker, sorted by the difficulty
+}
+
+// GetCurrentRoundStart returns the start timestamp of the current round
+func (db *Database) GetCurrentRoundStart() (int64, error) {
+	data, err := db.DB.Get([]byte(CurrentRoundStartKey), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(data) != 8 {
+		return 0, errors.New("Database is corrupted")
+	}
+
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+func roundStartBytes(roundStart int64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(roundStart))
+	return data
+}
+
+// StartRound writes the start of the new round, and prunes the best shares of the closed rounds
+func (db *Database) StartRound(roundStart int64) error {
+	if err := db.DB.Put([]byte(CurrentRoundStartKey), roundStartBytes(roundStart), nil); err != nil {
+		return err
+	}
+
+	return db.PruneBestShares(roundStart)
+}
+
+// RoundBoundary marks the end of the round when its block is submitted, so the shares
+// accounted while the block is harvested are counted in the next round
+type RoundBoundary struct {
+	Timestamp   int64
+	MinedHashes float64 // The mined hashes counter at the submission
+}
+
+// MarkRoundBoundary returns the round boundary at the current moment
+func (db *Database) MarkRoundBoundary() (RoundBoundary, error) {
+	db.sharesMux.Lock()
+	defer db.sharesMux.Unlock()
+
+	// The counter is unknown on error, the round is still closed with the timestamp
+	minedHashes, err := db.getFloatCounter(MinedHashesKey)
+	boundary := RoundBoundary{Timestamp: time.Now().Unix(), MinedHashes: minedHashes}
+	return boundary, errors.Wrap(err, "unable to read mined hashes counter from the db")
+}
+
+// CloseRound starts the new round at the boundary, and returns the closed round's duration and the hashes mined within it.
+// The mined hashes counter is never reset, the round's hashes are counted from the boundary of the previous round instead.
+func (db *Database) CloseRound(boundary RoundBoundary) (int64, float64, error) {
+	db.sharesMux.Lock()
+	defer db.sharesMux.Unlock()
+
+	var roundTime int64
+	if roundStart, err := db.GetCurrentRoundStart(); err == nil {
+		if boundary.Timestamp < roundStart {
+			// The block submitted after this one was harvested first, and has already closed the round
+			return 0, 0, nil
+		}
+		roundTime = boundary.Timestamp - roundStart
+	}
+
+	roundStartHashes, err := db.getFloatCounter(RoundStartHashesKey)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "unable to read round start hashes from the db")
+	}
+
+	// The round's hashes are unknown if the counter couldn't be read at the boundary
+	var minedHashes float64
+	batch := new(leveldb.Batch)
+	batch.Put([]byte(CurrentRoundStartKey), roundStartBytes(boundary.Timestamp))
+	if boundary.MinedHashes >= roundStartHashes {
+		minedHashes = boundary.MinedHashes - roundStartHashes
+		batch.Put([]byte(RoundStartHashesKey), []byte(strconv.FormatFloat(boundary.MinedHashes, 'f', -1, 64)))
+	}
+	if err := db.DB.Write(batch, nil); err != nil {
+		return 0, 0, err
+	}
+
+	return roundTime, minedHashes, db.PruneBestShares(boundary.Timestamp)
+}
+
+// PruneBestShares removes the best shares of the closed rounds, except the best share of every worker within the round
+func (db *Database) PruneBestShares(currentRoundStart int64) error {
+	type roundWorker struct {
+		roundStart int64
+		workerName string
+	}
+
+	bestKeys := make(map[roundWorker][]byte)
+	bestDifficulties := make(map[roundWorker]float64)
+	batch := new(leveldb.Batch)
+
+	iter := db.DB.NewIterator(util.BytesPrefix([]byte(BestSharePrefix)), nil)
+	for iter.Next() {
+		var bestShare BestShare
+		if err := msgpack.Unmarshal(iter.Value(), &bestShare); err != nil {
+			iter.Release()
+			return errors.Wrap(err, "Database is corrupted")
+		}
+
+		if bestShare.RoundStart >= currentRoundStart {
+			continue
+		}
+
+		key := make([]byte, len(iter.Key()))
+		copy(key, iter.Key())
+
+		rw := roundWorker{bestShare.RoundStart, bestShare.WorkerName}
+		if bestKey, ok := bestKeys[rw]; ok {
+			if bestDifficulties[rw] >= bestShare.ActualShareDifficulty {
+				batch.Delete(key)
+				continue
+			}
+			batch.Delete(bestKey)
+		}
+		bestKeys[rw] = key
+		bestDifficulties[rw] = bestShare.ActualShareDifficulty
+	}
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	return db.DB.Write(batch, nil)
+}
+
+// GetBestSharesByRound returns the best shares grouped by round (the latest first), optionally of a single worker
+func (db *Database) GetBestSharesByRound(workerName string) ([]RoundBestShares, error) {
+	currentRoundStart, err := db.GetCurrentRoundStart()
+	if err != nil && err != leveldb.ErrNotFound {
+		return nil, err
+	}
+
+	workerBests := make(map[int64]map[string]BestShare)
+	for _, bestShare := range db.GetUnsortedBestShares() {
+		if workerName != "" && bestShare.WorkerName != workerName {
+			continue
+		}
+
+		if workerBests[bestShare.RoundStart] == nil {
+			workerBests[bestShare.RoundStart] = make(map[string]BestShare)
+		}
+		if best, ok := workerBests[bestShare.RoundStart][bestShare.WorkerName]; !ok || best.ActualShareDifficulty < bestShare.ActualShareDifficulty {
+			workerBests[bestShare.RoundStart][bestShare.WorkerName] = bestShare
+		}
+	}
+
+	rounds := []RoundBestShares{}
+	for roundStart, workers := range workerBests {
+		round := RoundBestShares{
+			RoundStart: roundStart,
+			Current:    roundStart == currentRoundStart,
+		}
+		for _, bestShare := range workers {
+			round.Workers = append(round.Workers, bestShare)
+		}
+		sort.Slice(round.Workers, func(i, j int) bool {
+			return round.Workers[i].ActualShareDifficulty > round.Workers[j].ActualShareDifficulty
+		})
+		round.Best = round.Workers[0]
+		rounds = append(rounds, round)
+	}
+
+	sort.Slice(rounds, func(i, j int) bool {
+		return rounds[i].RoundStart > rounds[j].RoundStart
+	})
+
+	return rounds, nil
+}
+
+// GetRoundBestShares returns the best share of every worker within the round
+func (db *Database) GetRoundBestShares(roundStart int64) []BestShare {
+	workerBests := make(map[string]BestShare)
+	for _, bestShare := range db.GetUnsortedBestShares() {
+		if bestShare.RoundStart != roundStart {
+			continue
+		}
+		if best, ok := workerBests[bestShare.WorkerName]; !ok || best.ActualShareDifficulty < bestShare.ActualShareDifficulty {
+			workerBests[bestShare.WorkerName] = bestShare
+		}
+	}
+
+	var bestShares []BestShare
+	for _, bestShare := range workerBests {
+		bestShares = append(bestShares, bestShare)
+	}
+	return bestShares
+}
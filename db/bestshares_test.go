@@ -0,0 +1,121 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"sync"
+	"testing"
+)
+
+func markTestRoundBoundary(t *testing.T, database *Database, timestamp int64) RoundBoundary {
+	boundary, err := database.MarkRoundBoundary()
+	if err != nil {
+		t.Fatalf("unable to mark the round boundary: %v", err)
+	}
+	boundary.Timestamp = timestamp
+	return boundary
+}
+
+func TestCloseRound(t *testing.T) {
+	database := openTestDB(t)
+
+	if err := database.StartRound(1000); err != nil {
+		t.Fatalf("unable to start the round: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := database.IncrValidShares(4000000000); err != nil {
+			t.Fatalf("unable to increment the counters: %v", err)
+		}
+	}
+
+	boundary := markTestRoundBoundary(t, database, 1600)
+
+	// The share accounted while the block is harvested belongs to the next round
+	if err := database.IncrValidShares(4000000000); err != nil {
+		t.Fatalf("unable to increment the counters: %v", err)
+	}
+
+	roundTime, minedHashes, err := database.CloseRound(boundary)
+	if err != nil {
+		t.Fatalf("unable to close the round: %v", err)
+	}
+	if roundTime != 600 || minedHashes != 12000000000 {
+		t.Fatalf("expected round time 600 and 12000000000 mined hashes, got %d and %v", roundTime, minedHashes)
+	}
+
+	if roundStart, err := database.GetCurrentRoundStart(); err != nil || roundStart != 1600 {
+		t.Fatalf("expected the new round to start at 1600, got %d (%v)", roundStart, err)
+	}
+
+	_, minedHashes, err = database.CloseRound(markTestRoundBoundary(t, database, 2000))
+	if err != nil || minedHashes != 4000000000 {
+		t.Fatalf("expected 4000000000 mined hashes in the next round, got %v (%v)", minedHashes, err)
+	}
+
+	// The round was already closed by a later block
+	roundTime, minedHashes, err = database.CloseRound(RoundBoundary{Timestamp: 1800, MinedHashes: 16000000000})
+	if err != nil || roundTime != 0 || minedHashes != 0 {
+		t.Fatalf("expected the closed round to be skipped, got %d and %v (%v)", roundTime, minedHashes, err)
+	}
+	if roundStart, err := database.GetCurrentRoundStart(); err != nil || roundStart != 2000 {
+		t.Fatalf("expected the round to still start at 2000, got %d (%v)", roundStart, err)
+	}
+}
+
+func TestCloseRoundConcurrentShares(t *testing.T) {
+	database := openTestDB(t)
+
+	const (
+		workers         = 4
+		sharesPerWorker = 500
+		rounds          = 50
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < sharesPerWorker; j++ {
+				if err := database.IncrValidShares(1); err != nil {
+					t.Errorf("unable to increment the counters: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	var closedHashes float64
+	for i := int64(1); i <= rounds; i++ {
+		boundary := markTestRoundBoundary(t, database, i)
+		_, minedHashes, err := database.CloseRound(boundary)
+		if err != nil {
+			t.Fatalf("unable to close the round: %v", err)
+		}
+		closedHashes += minedHashes
+	}
+	wg.Wait()
+
+	// Every share is counted exactly once, either in a closed round or in the current one
+	_, currentHashes, err := database.CloseRound(markTestRoundBoundary(t, database, rounds+1))
+	if err != nil {
+		t.Fatalf("unable to close the round: %v", err)
+	}
+	if total := closedHashes + currentHashes; total != workers*sharesPerWorker {
+		t.Fatalf("expected %d mined hashes in total, got %v", workers*sharesPerWorker, total)
+	}
+}
@@ -0,0 +1,226 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/flexpool/solo/log"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// blockIndexEntry holds the block fields used for filtering and the aggregates,
+// so the queries don't have to read the full block objects
+type blockIndexEntry struct {
+	Hash       string   `msgpack:"hash"`
+	Type       string   `msgpack:"type"`
+	WorkerName string   `msgpack:"worker_name"`
+	Confirmed  bool     `msgpack:"confirmed"`
+	Luck       *float64 `msgpack:"luck"`
+}
+
+// BlockQuery specifies the sorting, the pagination and the filters of the mined blocks query
+type BlockQuery struct {
+	SortByTime bool // Sort by the block number otherwise
+	Ascending  bool
+	Offset     int
+	Limit      int
+	Type       string // "block", "uncle" or "orphan" (empty matches any)
+	Confirmed  *bool  // nil matches any
+	WorkerName string // empty matches any
+}
+
+// BlocksPage represents a page of the mined blocks, with the aggregates over all the blocks matching the query
+type BlocksPage struct {
+	Blocks      []Block `json:"blocks"`
+	TotalBlocks uint64  `json:"totalBlocks"`
+	UncleRate   float64 `json:"uncleRate"`
+	OrphanRate  float64 `json:"orphanRate"`
+	AverageLuck float64 `json:"averageLuck"`
+	MedianLuck  float64 `json:"medianLuck"`
+}
+
+func blockNumberIndexKey(block Block) []byte {
+	return []byte(fmt.Sprintf("%s%020d_%s", BlockNumberIndexPrefix, block.Number, block.Hash))
+}
+
+func blockTimeIndexKey(block Block) []byte {
+	return []byte(fmt.Sprintf("%s%020d_%s", BlockTimeIndexPrefix, block.Timestamp, block.Hash))
+}
+
+// writeMinedBlockToBatch writes the block and its secondary indexes to the LevelDB batch
+func writeMinedBlockToBatch(batch *leveldb.Batch, block Block) {
+	data, _ := msgpack.Marshal(block)
+	batch.Put([]byte(MinedBlockPrefix+block.Hash), data)
+
+	// Number, timestamp and hash never change, so the index keys are overwritten on updates
+	indexData, _ := msgpack.Marshal(blockIndexEntry{
+		Hash:       block.Hash,
+		Type:       block.Type,
+		WorkerName: block.WorkerName,
+		Confirmed:  block.Confirmed,
+		Luck:       block.Luck,
+	})
+	batch.Put(blockNumberIndexKey(block), indexData)
+	batch.Put(blockTimeIndexKey(block), indexData)
+}
+
+// IndexMinedBlocks builds the secondary indexes of the blocks written before they were introduced
+func (db *Database) IndexMinedBlocks() error {
+	if indexed, err := db.DB.Has([]byte(BlocksIndexedKey), nil); err != nil || indexed {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	blocks := db.GetBlocksUnsorted()
+	for _, block := range blocks {
+		writeMinedBlockToBatch(batch, block)
+	}
+	batch.Put([]byte(BlocksIndexedKey), []byte{1})
+
+	if err := db.DB.Write(batch, nil); err != nil {
+		return err
+	}
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix": "db",
+		"blocks": len(blocks),
+	}).Info("Indexed mined blocks")
+
+	return nil
+}
+
+// knownLuck returns nil if the luck is unknown. The blocks mined before the unknown luck was stored as nil
+// have zero luck instead, or even infinite luck if they were mined before it was guarded against the empty
+// mined hashes counter.
+func knownLuck(luck *float64) *float64 {
+	if luck == nil || *luck == 0 || math.IsInf(*luck, 0) || math.IsNaN(*luck) {
+		return nil
+	}
+	return luck
+}
+
+func (q BlockQuery) matches(entry blockIndexEntry) bool {
+	return (q.Type == "" || entry.Type == q.Type) &&
+		(q.Confirmed == nil || entry.Confirmed == *q.Confirmed) &&
+		(q.WorkerName == "" || entry.WorkerName == q.WorkerName)
+}
+
+// GetBlocks returns the page of the mined blocks matching the query
+func (db *Database) GetBlocks(query BlockQuery) (BlocksPage, error) {
+	prefix := BlockNumberIndexPrefix
+	if query.SortByTime {
+		prefix = BlockTimeIndexPrefix
+	}
+
+	iter := db.DB.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+
+	next := iter.Next
+	if !query.Ascending {
+		next = reverseIterator(iter)
+	}
+
+	var page BlocksPage
+	var lucks []float64
+	var uncles, orphans uint64
+	var hashes []string
+
+	for next() {
+		var entry blockIndexEntry
+		if err := msgpack.Unmarshal(iter.Value(), &entry); err != nil {
+			return BlocksPage{}, errors.Wrap(err, "Database is corrupted")
+		}
+
+		if !query.matches(entry) {
+			continue
+		}
+
+		if page.TotalBlocks >= uint64(query.Offset) && len(hashes) < query.Limit {
+			hashes = append(hashes, entry.Hash)
+		}
+
+		page.TotalBlocks++
+		if luck := knownLuck(entry.Luck); luck != nil {
+			lucks = append(lucks, *luck)
+		}
+		switch entry.Type {
+		case "uncle":
+			uncles++
+		case "orphan":
+			orphans++
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return BlocksPage{}, err
+	}
+
+	page.Blocks = []Block{}
+	for _, hash := range hashes {
+		data, err := db.DB.Get([]byte(MinedBlockPrefix+hash), nil)
+		if err != nil {
+			return BlocksPage{}, errors.Wrap(err, "Database is corrupted")
+		}
+
+		var block Block
+		if err := msgpack.Unmarshal(data, &block); err != nil {
+			return BlocksPage{}, errors.Wrap(err, "Database is corrupted")
+		}
+		block.Luck = knownLuck(block.Luck)
+		page.Blocks = append(page.Blocks, block)
+	}
+
+	if page.TotalBlocks != 0 {
+		page.UncleRate = float64(uncles) / float64(page.TotalBlocks)
+		page.OrphanRate = float64(orphans) / float64(page.TotalBlocks)
+	}
+
+	if len(lucks) != 0 {
+		for _, luck := range lucks {
+			page.AverageLuck += luck / float64(len(lucks))
+		}
+
+		sort.Float64s(lucks)
+		if len(lucks)%2 == 1 {
+			page.MedianLuck = lucks[len(lucks)/2]
+		} else {
+			page.MedianLuck = (lucks[len(lucks)/2-1] + lucks[len(lucks)/2]) / 2
+		}
+	}
+
+	return page, nil
+}
+
+// reverseIterator returns the function iterating from the last key to the first one
+func reverseIterator(iter iterator.Iterator) func() bool {
+	started := false
+	return func() bool {
+		if !started {
+			started = true
+			return iter.Last()
+		}
+		return iter.Prev()
+	}
+}
@@ -0,0 +1,169 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *Database {
+	database, err := OpenDB(t.TempDir(), StatsOptions{CollectionPeriodSecs: 600, RetentionSecs: 86400})
+	if err != nil {
+		t.Fatalf("unable to open the database: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	return database
+}
+
+func writeTestBlocks(t *testing.T, database *Database, lucks []float64) {
+	for i := range lucks {
+		blockType := "block"
+		if i%3 == 2 {
+			blockType = "uncle"
+		}
+
+		err := database.WriteMinedBlock(Block{
+			Hash:       "0x" + strconv.Itoa(i),
+			Number:     uint64(100 + i),
+			Type:       blockType,
+			WorkerName: "rig" + strconv.Itoa(i%2),
+			Timestamp:  int64(1000 - i), // Reversed, so the time order differs from the number order
+			Luck:       &lucks[i],
+		})
+		if err != nil {
+			t.Fatalf("unable to write the block: %v", err)
+		}
+	}
+}
+
+func blockNumbers(page BlocksPage) []uint64 {
+	var numbers []uint64
+	for _, block := range page.Blocks {
+		numbers = append(numbers, block.Number)
+	}
+	return numbers
+}
+
+func equalNumbers(a []uint64, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGetBlocksPaging(t *testing.T) {
+	database := openTestDB(t)
+	writeTestBlocks(t, database, []float64{1, 2, 3, 4, 5, 6, 7})
+
+	tests := []struct {
+		name        string
+		query       BlockQuery
+		wantNumbers []uint64
+		wantTotal   uint64
+	}{
+		{"first page, newest first", BlockQuery{Limit: 3}, []uint64{106, 105, 104}, 7},
+		{"last page, newest first", BlockQuery{Offset: 6, Limit: 3}, []uint64{100}, 7},
+		{"past the last page", BlockQuery{Offset: 9, Limit: 3}, nil, 7},
+		{"oldest first", BlockQuery{Ascending: true, Limit: 2}, []uint64{100, 101}, 7},
+		{"by time, newest first", BlockQuery{SortByTime: true, Limit: 2}, []uint64{100, 101}, 7},
+		{"uncles only", BlockQuery{Type: "uncle", Limit: 10}, []uint64{105, 102}, 2},
+		{"worker filter", BlockQuery{WorkerName: "rig1", Offset: 1, Limit: 10}, []uint64{103, 101}, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := database.GetBlocks(test.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if page.TotalBlocks != test.wantTotal {
+				t.Fatalf("expected %d total blocks, got %d", test.wantTotal, page.TotalBlocks)
+			}
+			if numbers := blockNumbers(page); !equalNumbers(numbers, test.wantNumbers) {
+				t.Fatalf("expected blocks %v, got %v", test.wantNumbers, numbers)
+			}
+		})
+	}
+}
+
+func TestGetBlocksAggregates(t *testing.T) {
+	tests := []struct {
+		name        string
+		lucks       []float64
+		wantAverage float64
+		wantMedian  float64
+	}{
+		{"odd count", []float64{3, 1, 2}, 2, 2},
+		{"even count", []float64{4, 1, 3, 2}, 2.5, 2.5},
+		{"non-finite lucks are skipped", []float64{1, math.Inf(1), 3, math.NaN()}, 2, 2},
+		{"unknown lucks are skipped", []float64{0, 2, 0, 4}, 3, 3},
+		{"only non-finite lucks", []float64{math.Inf(1)}, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			database := openTestDB(t)
+			writeTestBlocks(t, database, test.lucks)
+
+			page, err := database.GetBlocks(BlockQuery{Limit: 10})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if page.AverageLuck != test.wantAverage || page.MedianLuck != test.wantMedian {
+				t.Fatalf("expected average %v and median %v, got %v and %v", test.wantAverage, test.wantMedian, page.AverageLuck, page.MedianLuck)
+			}
+			for _, block := range page.Blocks {
+				if block.Luck != nil && (*block.Luck == 0 || math.IsInf(*block.Luck, 0) || math.IsNaN(*block.Luck)) {
+					t.Fatalf("block %s has unknown luck %v instead of nil", block.Hash, *block.Luck)
+				}
+			}
+		})
+	}
+}
+
+func TestGetBlocksUnknownLuck(t *testing.T) {
+	database := openTestDB(t)
+	if err := database.WriteMinedBlock(Block{Hash: "0x1", Number: 100, Type: "block"}); err != nil {
+		t.Fatalf("unable to write the block: %v", err)
+	}
+
+	page, err := database.GetBlocks(BlockQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Blocks) != 1 || page.Blocks[0].Luck != nil {
+		t.Fatalf("expected a single block with unknown luck, got %+v", page.Blocks)
+	}
+
+	// Unknown luck is rendered as null, not as 0% luck
+	data, err := json.Marshal(page.Blocks[0])
+	if err != nil {
+		t.Fatalf("unable to marshal the block: %v", err)
+	}
+	if !strings.Contains(string(data), `"luck":null`) {
+		t.Fatalf("expected null luck, got %s", data)
+	}
+}
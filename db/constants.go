@@ -31,8 +31,38 @@ const MinedBlockPrefix = "block__"
 // MinedValidSharesKey is used to identify if key is mined valid shares counter
 const MinedValidSharesKey = "mined_valid_shares"
 
+// MinedHashesKey is used to identify if key is mined hashes counter (sum of valid shares difficulties, used to precisely calculate luck)
+const MinedHashesKey = "mined_hashes"
+
+// RoundStartHashesKey is used to identify if key is the mined hashes counter value at the start of the current round
+const RoundStartHashesKey = "round_start_hashes"
+
 // AverageTotalHashrateKey is used to identify if key is average total hashrate item
 const AverageTotalHashrateKey = "average_total_hashrate"
 
 // TotalSharesKey is used to identify if key is total shares item
 const TotalSharesKey = "total_shares"
+
+// WorkerCredentialPrefix is used to map worker credential db objects
+const WorkerCredentialPrefix = "credential__"
+
+// BanPrefix is used to map IP ban db objects
+const BanPrefix = "ban__"
+
+// PendingStatsKey is used to identify if key is the Stats Collector's pending stats checkpoint
+const PendingStatsKey = "pending_stats"
+
+// BlockNumberIndexPrefix is used to map the mined blocks secondary index sorted by the block number
+const BlockNumberIndexPrefix = "blocknumidx__"
+
+// BlockTimeIndexPrefix is used to map the mined blocks secondary index sorted by the block timestamp
+const BlockTimeIndexPrefix = "blocktimeidx__"
+
+// BlocksIndexedKey is used to identify if the mined blocks secondary indexes were built
+const BlocksIndexedKey = "blocks_indexed"
+
+// CurrentRoundStartKey is used to identify if key is the current round start timestamp
+const CurrentRoundStartKey = "current_round_start"
+
+// StatsHashrateNormalizedKey is used to identify if the effective hashrate of the stats written before it was stored in H/s was rescaled
+const StatsHashrateNormalizedKey = "stats_hashrate_normalized"
@@ -0,0 +1,80 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// WorkerCredential represents an interface for a worker credential DB object
+type WorkerCredential struct {
+	NamePattern  string   `msgpack:"name_pattern" json:"namePattern"` // Worker name or glob pattern (e.g. "friend-*")
+	PasswordHash string   `msgpack:"password_hash" json:"-"`          // bcrypt hash
+	Enabled      bool     `msgpack:"enabled" json:"enabled"`
+	AllowedIPs   []string `msgpack:"allowed_ips" json:"allowedIPs"` // IP addresses or CIDR networks, empty means any
+	CreatedAt    int64    `msgpack:"created_at" json:"createdAt"`
+}
+
+// WriteWorkerCredential writes (or replaces) the worker credential to the database
+func (db *Database) WriteWorkerCredential(credential WorkerCredential) error {
+	data, _ := msgpack.Marshal(credential)
+	key := WorkerCredentialPrefix + credential.NamePattern
+	return db.DB.Put([]byte(key), data, nil)
+}
+
+// GetWorkerCredential returns the worker credential by its name pattern
+func (db *Database) GetWorkerCredential(namePattern string) (WorkerCredential, error) {
+	data, err := db.DB.Get([]byte(WorkerCredentialPrefix+namePattern), nil)
+	if err != nil {
+		return WorkerCredential{}, err
+	}
+
+	var credential WorkerCredential
+	err = msgpack.Unmarshal(data, &credential)
+	return credential, err
+}
+
+// GetWorkerCredentials returns all worker credentials from the database
+func (db *Database) GetWorkerCredentials() ([]WorkerCredential, error) {
+	var credentials []WorkerCredential
+	iter := db.DB.NewIterator(util.BytesPrefix([]byte(WorkerCredentialPrefix)), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		var credential WorkerCredential
+		if err := msgpack.Unmarshal(iter.Value(), &credential); err != nil {
+			return nil, errors.Wrap(err, "Database is corrupted")
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, iter.Error()
+}
+
+// DeleteWorkerCredential removes the worker credential from the database
+func (db *Database) DeleteWorkerCredential(namePattern string) error {
+	key := []byte(WorkerCredentialPrefix + namePattern)
+	if ok, err := db.DB.Has(key, nil); err != nil {
+		return err
+	} else if !ok {
+		return leveldb.ErrNotFound
+	}
+	return db.DB.Delete(key, nil)
+}
@@ -17,23 +17,45 @@
 package db
 
 import (
+	"sync"
+
+	"github.com/flexpool/solo/utils"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
+// StatsOptions specifies the size and the retention of the stat buckets
+type StatsOptions struct {
+	CollectionPeriodSecs int64 // Stats are collected into a bucket every period
+	RetentionSecs        int64 // Stats older than this are pruned
+}
+
+// HistoryLength returns the number of the stat buckets within the retention
+func (o StatsOptions) HistoryLength() int64 {
+	return o.RetentionSecs / o.CollectionPeriodSecs
+}
+
 // Database represents the interface to the LevelDB database
 type Database struct {
-	DB   *leveldb.DB
-	Path string
+	DB           *leveldb.DB
+	Path         string
+	StatsOptions StatsOptions
+
+	sharesMux sync.Mutex // Serializes the mined shares counters updates
 }
 
 // OpenDB opens LevelDB database by path, and creates a new Database object
-func OpenDB(path string) (*Database, error) {
+func OpenDB(path string, statsOptions StatsOptions) (*Database, error) {
 	db, err := leveldb.OpenFile(path, &opt.Options{})
 	if err != nil {
 		return nil, err
 	}
-	return &Database{DB: db, Path: path}, nil
+	return &Database{DB: db, Path: path, StatsOptions: statsOptions}, nil
+}
+
+// CurrentStatTimestamp returns the timestamp of the latest collected stat bucket
+func (db *Database) CurrentStatTimestamp() int64 {
+	return utils.GetCurrentPeriodTimestamp(db.StatsOptions.CollectionPeriodSecs)
 }
 
 // Close closes LevelDB database
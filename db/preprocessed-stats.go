@@ -25,9 +25,10 @@ import (
 
 // TotalShares represents an interface to total shares db object
 type TotalShares struct {
-	ValidShares   uint64 `msgpack:"valid_shares"`
-	StaleShares   uint64 `msgpack:"stale_shares"`
-	InvalidShares uint64 `msgpack:"invalidShares"`
+	ValidShares     uint64 `msgpack:"valid_shares"`
+	StaleShares     uint64 `msgpack:"stale_shares"`
+	InvalidShares   uint64 `msgpack:"invalidShares"`
+	DuplicateShares uint64 `msgpack:"duplicate_shares"`
 }
 
 // GetAndWriteCachedValues gets (calculates) and writes cached values to the db
@@ -41,6 +42,7 @@ func (db *Database) GetAndWriteCachedValues() error {
 	var totalValidShares uint64
 	var totalStaleShares uint64
 	var totalInvalidShares uint64
+	var totalDuplicateShares uint64
 
 	for _, item := range history {
 		if item.EffectiveHashrate != 0 {
@@ -49,6 +51,7 @@ func (db *Database) GetAndWriteCachedValues() error {
 		totalValidShares += item.ValidShareCount
 		totalStaleShares += item.StaleShareCount
 		totalInvalidShares += item.InvalidShareCount
+		totalDuplicateShares += item.DuplicateShareCount
 	}
 
 	effectiveNoZeroesLen := float64(len(effectiveNoZeroes))
@@ -56,7 +59,7 @@ func (db *Database) GetAndWriteCachedValues() error {
 	avgHashrate := big.NewFloat(0)
 
 	for _, item := range effectiveNoZeroes {
-		avgHashrate.Set(big.NewFloat(0).Add(avgHashrate, big.NewFloat(item/effectiveNoZeroesLen))) // 144 is the length of history
+		avgHashrate.Set(big.NewFloat(0).Add(avgHashrate, big.NewFloat(item/effectiveNoZeroesLen)))
 	}
 
 	avgHashrateInt, _ := avgHashrate.Int(nil)
@@ -66,9 +69,10 @@ func (db *Database) GetAndWriteCachedValues() error {
 		return err
 	}
 	data, _ := msgpack.Marshal(TotalShares{
-		ValidShares:   totalValidShares,
-		StaleShares:   totalStaleShares,
-		InvalidShares: totalInvalidShares,
+		ValidShares:     totalValidShares,
+		StaleShares:     totalStaleShares,
+		InvalidShares:   totalInvalidShares,
+		DuplicateShares: totalDuplicateShares,
 	})
 
 	return db.DB.Put([]byte(TotalSharesKey), data, nil)
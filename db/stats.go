@@ -25,7 +25,6 @@ import (
 	"time"
 
 	"github.com/flexpool/solo/log"
-	"github.com/flexpool/solo/utils"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -36,44 +35,47 @@ import (
 
 // Stat represents an interface for a stat DB object
 type Stat struct {
-	WorkerName        string  `msgpack:"worker_name"`
-	ValidShareCount   uint64  `msgpack:"valid_share_count"`
-	StaleShareCount   uint64  `msgpack:"stale_share_count"`
-	InvalidShareCount uint64  `msgpack:"invalid_share_count"`
-	ReportedHashrate  float64 `msgpack:"reported_hashrate"`
-	EffectiveHashrate float64 `msgpack:"effective_hashrate"`
-	IPAddress         string  `msgpack:"ip_address"`
+	WorkerName          string  `msgpack:"worker_name"`
+	ValidShareCount     uint64  `msgpack:"valid_share_count"`
+	StaleShareCount     uint64  `msgpack:"stale_share_count"`
+	InvalidShareCount   uint64  `msgpack:"invalid_share_count"`
+	DuplicateShareCount uint64  `msgpack:"duplicate_share_count"`
+	ReportedHashrate    float64 `msgpack:"reported_hashrate"`
+	EffectiveHashrate   float64 `msgpack:"effective_hashrate"`
+	IPAddress           string  `msgpack:"ip_address"`
 }
 
 // TotalStat represents an interface for a summarized stat DB object
 type TotalStat struct {
-	ValidShareCount   uint64  `msgpack:"valid_share_count" json:"validShares"`
-	StaleShareCount   uint64  `msgpack:"stale_share_count"`
-	InvalidShareCount uint64  `msgpack:"invalid_share_count"`
-	ReportedHashrate  float64 `msgpack:"reported_hashrate"`
-	EffectiveHashrate float64 `msgpack:"effective_hashrate"`
-	WorkerCount       uint64  `msgpack:"worker_count"`
+	ValidShareCount     uint64  `msgpack:"valid_share_count" json:"validShares"`
+	StaleShareCount     uint64  `msgpack:"stale_share_count"`
+	InvalidShareCount   uint64  `msgpack:"invalid_share_count"`
+	DuplicateShareCount uint64  `msgpack:"duplicate_share_count"`
+	ReportedHashrate    float64 `msgpack:"reported_hashrate"`
+	EffectiveHashrate   float64 `msgpack:"effective_hashrate"`
+	WorkerCount         uint64  `msgpack:"worker_count"`
 }
 
 // BestShare represents an interface for a best share DB object
 type BestShare struct {
-	WorkerName            string  `msgpack:"worker_name"`
-	ActualShareDifficulty float64 `msgpack:"actual_share_difficulty"`
-	Timestamp             int64   `msgpack:"timestamp"`
+	WorkerName            string  `msgpack:"worker_name" json:"workerName"`
+	ActualShareDifficulty float64 `msgpack:"actual_share_difficulty" json:"actualShareDifficulty"`
+	Timestamp             int64   `msgpack:"timestamp" json:"timestamp"`
+	RoundStart            int64   `msgpack:"round_start" json:"roundStart"` // Zero for the best shares written before the rounds were tracked
 }
 
 // Block represents an interface for a block DB object
 type Block struct {
-	Hash        string  `msgpack:"hash"`
-	Number      uint64  `msgpack:"number"`
-	Type        string  `msgpack:"type"`
-	WorkerName  string  `msgpack:"worker_name"`
-	Difficulty  float64 `msgpack:"difficulty"`
-	Timestamp   int64   `msgpack:"timestamp"`
-	Confirmed   bool    `msgpack:"confirmed"`
-	MinedHashes float64 `msgpack:"mined_hashes"`
-	RoundTime   int64   `msgpack:"round_time"`
-	Luck        float64 `msgpack:"luck"`
+	Hash        string   `msgpack:"hash" json:"hash"`
+	Number      uint64   `msgpack:"number" json:"number"`
+	Type        string   `msgpack:"type" json:"type"`
+	WorkerName  string   `msgpack:"worker_name" json:"workerName"`
+	Difficulty  float64  `msgpack:"difficulty" json:"difficulty"`
+	Timestamp   int64    `msgpack:"timestamp" json:"timestamp"`
+	Confirmed   bool     `msgpack:"confirmed" json:"confirmed"`
+	MinedHashes float64  `msgpack:"mined_hashes" json:"minedHashes"`
+	RoundTime   int64    `msgpack:"round_time" json:"roundTime"`
+	Luck        *float64 `msgpack:"luck" json:"luck"` // nil if the mined hashes of the round are unknown
 }
 
 // WriteStatToBatch writes worker stat object to the LevelDB batch
@@ -83,6 +85,17 @@ func WriteStatToBatch(batch *leveldb.Batch, stat Stat, timestamp int64) {
 	batch.Put([]byte(key), data)
 }
 
+// parseStatKey returns the timestamp and the worker name (empty for the total stats) of the stat key
+func parseStatKey(key string, prefix string) (int64, string, error) {
+	// Worker stat keys are "stat__<timestamp>_<worker-name>", total stat keys are "total___<timestamp>"
+	keySplitted := strings.SplitN(strings.TrimPrefix(strings.TrimPrefix(key, prefix), "_"), "_", 2)
+	timestamp, err := strconv.ParseInt(keySplitted[0], 10, 64)
+	if len(keySplitted) == 1 {
+		return timestamp, "", err
+	}
+	return timestamp, keySplitted[1], err
+}
+
 // WriteTotalStatToBatch writes worker stat object to the LevelDB batch
 func WriteTotalStatToBatch(batch *leveldb.Batch, stat TotalStat, timestamp int64) {
 	data, _ := msgpack.Marshal(stat)
@@ -90,16 +103,20 @@ func WriteTotalStatToBatch(batch *leveldb.Batch, stat TotalStat, timestamp int64
 	batch.Put([]byte(key), data)
 }
 
-// PruneStats removes data with the age more than `deleteDataOlderThanSecs`
+// PruneStats removes data (both worker and total stats) with the age more than `deleteDataOlderThanSecs`
 func (db *Database) PruneStats(deleteDataOlderThanSecs int64) {
-	iter := db.DB.NewIterator(util.BytesPrefix([]byte(StatPrefix)), nil)
-
 	deleteWithTimestampLowerThan := time.Now().Unix() - deleteDataOlderThanSecs
 
+	db.pruneStatsWithPrefix(StatPrefix, deleteWithTimestampLowerThan)
+	db.pruneStatsWithPrefix(TotalStatPrefix, deleteWithTimestampLowerThan)
+}
+
+func (db *Database) pruneStatsWithPrefix(prefix string, deleteWithTimestampLowerThan int64) {
+	iter := db.DB.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+
 	for iter.Next() {
 		key := iter.Key()
-		keySplitted := strings.Split(string(key), "_")
-		timestamp, err := strconv.ParseInt(keySplitted[len(keySplitted)-1], 10, 64)
+		timestamp, _, err := parseStatKey(string(key), prefix)
 		if err != nil {
 			panic(errors.Wrap(err, "Database is corrupted"))
 		}
@@ -112,11 +129,68 @@ func (db *Database) PruneStats(deleteDataOlderThanSecs int64) {
 	iter.Release()
 }
 
-// WriteMinedBlock writes mined block to the database
+// legacyStatCollectionPeriodSecs is the stat bucket size of the databases that stored the effective hashrate in hashes per bucket
+const legacyStatCollectionPeriodSecs = 600
+
+// NormalizeStatsHashrate rescales the effective hashrate of the stats written before it was stored in H/s
+func (db *Database) NormalizeStatsHashrate() error {
+	if normalized, err := db.DB.Has([]byte(StatsHashrateNormalizedKey), nil); err != nil || normalized {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+
+	iter := db.DB.NewIterator(util.BytesPrefix([]byte(StatPrefix)), nil)
+	for iter.Next() {
+		var stat Stat
+		if err := msgpack.Unmarshal(iter.Value(), &stat); err != nil {
+			iter.Release()
+			return errors.Wrap(err, "Database is corrupted")
+		}
+		stat.EffectiveHashrate /= legacyStatCollectionPeriodSecs
+		data, _ := msgpack.Marshal(stat)
+		batch.Put(append([]byte{}, iter.Key()...), data)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	iter = db.DB.NewIterator(util.BytesPrefix([]byte(TotalStatPrefix)), nil)
+	for iter.Next() {
+		var totalStat TotalStat
+		if err := msgpack.Unmarshal(iter.Value(), &totalStat); err != nil {
+			iter.Release()
+			return errors.Wrap(err, "Database is corrupted")
+		}
+		totalStat.EffectiveHashrate /= legacyStatCollectionPeriodSecs
+		data, _ := msgpack.Marshal(totalStat)
+		batch.Put(append([]byte{}, iter.Key()...), data)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	batch.Put([]byte(StatsHashrateNormalizedKey), []byte{1})
+	if err := db.DB.Write(batch, nil); err != nil {
+		return err
+	}
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix": "db",
+		"stats":  batch.Len() - 1,
+	}).Info("Normalized stats effective hashrate to H/s")
+
+	// The cached averages were calculated from the old values
+	return db.GetAndWriteCachedValues()
+}
+
+// WriteMinedBlock writes (or updates) mined block and its secondary indexes to the database
 func (db *Database) WriteMinedBlock(block Block) error {
-	data, _ := msgpack.Marshal(block)
-	key := MinedBlockPrefix + block.Hash
-	return db.DB.Put([]byte(key), data, nil)
+	batch := new(leveldb.Batch)
+	writeMinedBlockToBatch(batch, block)
+	return db.DB.Write(batch, nil)
 }
 
 // WriteBestShare writes best share  to the database
@@ -126,27 +200,61 @@ func (db *Database) WriteBestShare(bestShare BestShare, timestamp int64) error {
 	return db.DB.Put([]byte(key), data, nil)
 }
 
-// IncrValidShares increments mined valid shares counter (used to precisely calculate luck)
-func (db *Database) IncrValidShares() error {
+// IncrValidShares increments mined valid shares and mined hashes counters (used to precisely calculate luck)
+func (db *Database) IncrValidShares(shareDifficulty uint64) error {
+	db.sharesMux.Lock()
+	defer db.sharesMux.Unlock()
+
 	prevValBytes, _ := db.DB.Get([]byte(MinedValidSharesKey), nil)
 	prevVal, _ := strconv.ParseUint(string(prevValBytes), 10, 64)
-	return db.DB.Put([]byte(MinedValidSharesKey), []byte(string(strconv.FormatUint(prevVal+1, 10))), nil)
+	prevHashesBytes, _ := db.DB.Get([]byte(MinedHashesKey), nil)
+	prevHashes, _ := strconv.ParseFloat(string(prevHashesBytes), 64)
+
+	batch := new(leveldb.Batch)
+	batch.Put([]byte(MinedValidSharesKey), []byte(strconv.FormatUint(prevVal+1, 10)))
+	batch.Put([]byte(MinedHashesKey), []byte(strconv.FormatFloat(prevHashes+float64(shareDifficulty), 'f', -1, 64)))
+	return db.DB.Write(batch, nil)
 }
 
-// GetValidSharesThenReset gets the mined valid shares counter and resets it
-func (db *Database) GetValidSharesThenReset() (uint64, error) {
+// SeedMinedHashes seeds the mined hashes counter from the mined valid shares counter of the databases created before it existed
+func (db *Database) SeedMinedHashes(shareDifficulty uint64) error {
+	db.sharesMux.Lock()
+	defer db.sharesMux.Unlock()
+
+	if seeded, err := db.DB.Has([]byte(MinedHashesKey), nil); err != nil || seeded {
+		return err
+	}
+
 	valBytes, err := db.DB.Get([]byte(MinedValidSharesKey), nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	validShares, err := strconv.ParseUint(string(valBytes), 10, 64)
 	if err != nil {
-		return 0, errors.Wrap(err, "unable to read valid share counter from the db")
+		return errors.Wrap(err, "unable to parse mined valid shares counter")
+	}
+
+	// Before the vardiff, every share had the same difficulty
+	minedHashes := float64(validShares) * float64(shareDifficulty)
+	return db.DB.Put([]byte(MinedHashesKey), []byte(strconv.FormatFloat(minedHashes, 'f', -1, 64)), nil)
+}
+
+// getFloatCounter returns the counter by key, missing counter is zero
+func (db *Database) getFloatCounter(key string) (float64, error) {
+	valBytes, err := db.DB.Get([]byte(key), nil)
+	if err == leveldb.ErrNotFound {
+		return 0, nil
 	}
-	db.DB.Delete([]byte(MinedValidSharesKey), nil)
 	if err != nil {
-		log.Logger.WithFields(logrus.Fields{
-			"prefix": "db",
-			"error":  err,
-		}).Error("Unable to delete mined valid shares counter")
+		return 0, err
 	}
-	return strconv.ParseUint(string(valBytes), 10, 64)
+
+	val, err := strconv.ParseFloat(string(valBytes), 64)
+	return val, errors.Wrap(err, "Database is corrupted")
 }
 
 // GetTotalStatsByTimestamp returns TotalStat by specified timestamp
@@ -161,32 +269,14 @@ func (db *Database) GetTotalStatsByTimestamp(timestamp int64) (TotalStat, error)
 	return parsedData, err
 }
 
-// GetRoundTime returns round time
+// GetRoundTime returns the duration (in seconds) of the current round
 func (db *Database) GetRoundTime() int64 {
-	blocks := db.GetBlocksUnsorted()
-
-	if len(blocks) > 0 {
-		var latestBlockTimestamp int64 = time.Now().Unix()
-		for _, block := range blocks {
-			if latestBlockTimestamp > block.Timestamp {
-				latestBlockTimestamp = block.Timestamp
-			}
-		}
-		return time.Now().Unix() - latestBlockTimestamp
-	}
-
-	// Search for first best share
-	bestShares := db.GetUnsortedBestShares()
-
-	var earliestBestShareTimestamp int64 = time.Now().Unix()
-
-	for _, bestShare := range bestShares {
-		if earliestBestShareTimestamp > bestShare.Timestamp {
-			earliestBestShareTimestamp = bestShare.Timestamp
-		}
+	roundStart, err := db.GetCurrentRoundStart()
+	if err != nil {
+		return 0
 	}
 
-	return time.Now().Unix() - earliestBestShareTimestamp
+	return time.Now().Unix() - roundStart
 }
 
 // GetBlocksUnsorted returns the unsorted blocks from the Database
@@ -216,17 +306,21 @@ func (db *Database) GetBlocksUnsorted() []Block {
 	return blocks
 }
 
-// GetTotalHistory returns all "total" history (a day)
+// GetTotalHistory returns all "total" history (within the stats retention), missing buckets are left empty
 func (db *Database) GetTotalHistory() ([]TotalStat, error) {
-	var history = make([]TotalStat, 144)
-	ts := utils.GetCurrent10MinTimestamp()
-	for i := int64(0); i < 144; i++ {
-		stat, err := db.GetTotalStatsByTimestamp(ts - i*600)
+	historyLength := db.StatsOptions.HistoryLength()
+	var history = make([]TotalStat, historyLength)
+	ts := db.CurrentStatTimestamp()
+	for i := int64(0); i < historyLength; i++ {
+		stat, err := db.GetTotalStatsByTimestamp(ts - i*db.StatsOptions.CollectionPeriodSecs)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
 		if err != nil {
 			return history, err
 		}
 
-		history[144-1-i] = stat
+		history[historyLength-1-i] = stat
 	}
 
 	return history, nil
@@ -237,7 +331,9 @@ func (db *Database) GetUnsortedBestShares() []BestShare {
 	var bestShares []BestShare
 	iter := db.DB.NewIterator(util.BytesPrefix([]byte(BestSharePrefix)), nil)
 	for iter.Next() {
-		timestampString := strings.Split(strings.Split(strings.Replace(string(iter.Key()), BestSharePrefix, "", 1), "__")[0], "_")[1]
+		// The worker name may contain underscores, so parsing from the end of "best__<worker-name>_<timestamp>_<random>"
+		keySplitted := strings.Split(string(iter.Key()), "_")
+		timestampString := keySplitted[len(keySplitted)-2]
 		timestamp, err := strconv.ParseInt(timestampString, 10, 64)
 		if err != nil {
 			panic(errors.Wrap(err, "Database is corrupted"))
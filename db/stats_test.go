@@ -0,0 +1,70 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func TestNormalizeStatsHashrate(t *testing.T) {
+	database := openTestDB(t)
+
+	batch := new(leveldb.Batch)
+	WriteStatToBatch(batch, Stat{WorkerName: "rig_1", EffectiveHashrate: 600000}, 1200)
+	WriteTotalStatToBatch(batch, TotalStat{EffectiveHashrate: 1200000}, 1200)
+	if err := database.DB.Write(batch, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second run must not rescale the stats again
+	for i := 0; i < 2; i++ {
+		if err := database.NormalizeStatsHashrate(); err != nil {
+			t.Fatalf("unable to normalize the stats: %v", err)
+		}
+	}
+
+	stat, err := database.GetStatsByTimestamp("rig_1", 1200)
+	if err != nil || stat.EffectiveHashrate != 1000 {
+		t.Fatalf("expected the worker stat to be rescaled to 1000 H/s, got %v (%v)", stat.EffectiveHashrate, err)
+	}
+
+	totalStat, err := database.GetTotalStatsByTimestamp(1200)
+	if err != nil || totalStat.EffectiveHashrate != 2000 {
+		t.Fatalf("expected the total stat to be rescaled to 2000 H/s, got %v (%v)", totalStat.EffectiveHashrate, err)
+	}
+}
+
+func TestParseStatKey(t *testing.T) {
+	tests := []struct {
+		key            string
+		prefix         string
+		wantTimestamp  int64
+		wantWorkerName string
+	}{
+		{StatPrefix + "1200_rig_1", StatPrefix, 1200, "rig_1"},
+		{TotalStatPrefix + "_1200", TotalStatPrefix, 1200, ""},
+	}
+
+	for _, test := range tests {
+		timestamp, workerName, err := parseStatKey(test.key, test.prefix)
+		if err != nil || timestamp != test.wantTimestamp || workerName != test.wantWorkerName {
+			t.Errorf("%s: expected %d and %q, got %d and %q (%v)", test.key, test.wantTimestamp, test.wantWorkerName, timestamp, workerName, err)
+		}
+	}
+}
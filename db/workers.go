@@ -0,0 +1,106 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// WorkerSummary represents the worker's stats summarized over the stats retention
+type WorkerSummary struct {
+	EffectiveHashrate   float64 // Of the latest collected stat
+	ReportedHashrate    float64 // Of the latest collected stat
+	ValidShareCount     uint64
+	StaleShareCount     uint64
+	InvalidShareCount   uint64
+	DuplicateShareCount uint64
+	LastSeen            int64 // Timestamp of the latest stat the worker has submitted shares in
+}
+
+// GetStatsByTimestamp returns the worker's Stat by specified timestamp
+func (db *Database) GetStatsByTimestamp(workerName string, timestamp int64) (Stat, error) {
+	key := StatPrefix + strconv.FormatInt(timestamp, 10) + "_" + workerName
+	data, err := db.DB.Get([]byte(key), nil)
+	if err != nil {
+		return Stat{}, err
+	}
+	var parsedData Stat
+	err = msgpack.Unmarshal(data, &parsedData)
+	return parsedData, err
+}
+
+// GetWorkerHistory returns the worker's history (within the stats retention), missing buckets are left empty
+func (db *Database) GetWorkerHistory(workerName string) ([]Stat, error) {
+	historyLength := db.StatsOptions.HistoryLength()
+	var history = make([]Stat, historyLength)
+	ts := db.CurrentStatTimestamp()
+	for i := int64(0); i < historyLength; i++ {
+		stat, err := db.GetStatsByTimestamp(workerName, ts-i*db.StatsOptions.CollectionPeriodSecs)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return history, err
+		}
+
+		history[historyLength-1-i] = stat
+	}
+
+	return history, nil
+}
+
+// GetWorkerSummaries returns the summaries of all workers with stats within the retention
+func (db *Database) GetWorkerSummaries() (map[string]WorkerSummary, error) {
+	summaries := make(map[string]WorkerSummary)
+	currentTimestamp := db.CurrentStatTimestamp()
+
+	iter := db.DB.NewIterator(util.BytesPrefix([]byte(StatPrefix)), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		timestamp, workerName, err := parseStatKey(string(iter.Key()), StatPrefix)
+		if err != nil {
+			return nil, errors.Wrap(err, "Database is corrupted")
+		}
+
+		var stat Stat
+		if err := msgpack.Unmarshal(iter.Value(), &stat); err != nil {
+			return nil, errors.Wrap(err, "Database is corrupted")
+		}
+
+		summary := summaries[workerName]
+		summary.ValidShareCount += stat.ValidShareCount
+		summary.StaleShareCount += stat.StaleShareCount
+		summary.InvalidShareCount += stat.InvalidShareCount
+		summary.DuplicateShareCount += stat.DuplicateShareCount
+		if timestamp > summary.LastSeen {
+			summary.LastSeen = timestamp
+		}
+		if timestamp == currentTimestamp {
+			summary.EffectiveHashrate = stat.EffectiveHashrate
+			summary.ReportedHashrate = stat.ReportedHashrate
+		}
+		summaries[workerName] = summary
+	}
+
+	return summaries, iter.Error()
+}
@@ -18,6 +18,7 @@ package engine
 
 import (
 	"sync"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/flexpool/solo/db"
@@ -34,11 +35,14 @@ import (
 // MiningEngine represents the Flexpool Solo mining engine
 type MiningEngine struct {
 	workmanagerNotificationsBind string
+	workPollInterval             time.Duration
 	webServerBind                string
 	shareDifficulty              uint64
 
+	Node                     *nodeapi.NodePool
 	Workmanager              *gateway.WorkManager
 	Gateways                 []*gateway.Gateway
+	BanManager               *gateway.BanManager
 	StatsCollector           *stats.Collector
 	Database                 *db.Database
 	BlockConfirmationManager *stats.BlockConfirmationManager
@@ -48,49 +52,111 @@ type MiningEngine struct {
 	waitGroup *sync.WaitGroup
 }
 
+// Options specifies the configuration of the Mining Engine and its components
+type Options struct {
+	WorkmanagerNotificationsBind string
+	NotificationAuth             gateway.NotificationAuthOptions
+	WorkPollInterval             time.Duration
+	WorkWatchdogTimeout          time.Duration
+	ShareDifficulty              uint64
+	Vardiff                      gateway.VardiffOptions
+	GatewayInsecureBind          string
+	GatewaySecureBind            string
+	GatewayTLSCertFile           string
+	GatewayTLSKeyFile            string
+	GatewayPassword              string
+	Ban                          gateway.BanOptions
+	ConnectionLimits             gateway.ConnectionLimits
+	ProxyProtocol                gateway.ProxyProtocolOptions
+	NodeHTTPRPCs                 []string
+	NodeWSRPCs                   []string
+	NodeHealthCheck              nodeapi.HealthCheckOptions
+	DatabasePath                 string
+	Stats                        db.StatsOptions
+	BlockConfirmationsRequired   uint64
+	WebServerBind                string
+	WebServerAdminToken          string
+	WebServerCORSAllowedOrigins  []string
+}
+
 // NewMiningEngine creates a new Mining Engine
-func NewMiningEngine(workmanagerNotificationsBind string, shareDifficulty uint64, insecureStratumBind string, secureStratumBind string, stratumPassword string, nodeHTTPRPC string, databasePath string, blockConfirmationsRequired uint64, webServerBind string) (*MiningEngine, error) {
-	node, err := nodeapi.NewNode(nodeHTTPRPC)
+func NewMiningEngine(options Options) (*MiningEngine, error) {
+	waitGroup := new(sync.WaitGroup)
+
+	node, err := nodeapi.NewNodePool(options.NodeHTTPRPCs, options.NodeWSRPCs, options.NodeHealthCheck, waitGroup)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create Node")
 	}
 
-	database, err := db.OpenDB(databasePath)
+	database, err := db.OpenDB(options.DatabasePath, options.Stats)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open db")
 	}
 
-	waitGroup := new(sync.WaitGroup)
+	if err := database.IndexMinedBlocks(); err != nil {
+		return nil, errors.Wrap(err, "unable to index mined blocks")
+	}
+
+	if err := database.SeedMinedHashes(options.ShareDifficulty); err != nil {
+		return nil, errors.Wrap(err, "unable to seed mined hashes counter")
+	}
+
+	if err := database.NormalizeStatsHashrate(); err != nil {
+		return nil, errors.Wrap(err, "unable to normalize stats hashrate")
+	}
+
+	banManager, err := gateway.NewBanManager(options.Ban, database, waitGroup)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create Ban Manager")
+	}
+
+	connLimiter := gateway.NewConnectionLimiter(options.ConnectionLimits)
+	sessionRegistry := gateway.NewSessionRegistry()
+
+	credentials, err := gateway.NewCredentialStore(database)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load worker credentials")
+	}
 
-	statsCollector := stats.NewCollector(database, waitGroup, shareDifficulty)
-	blockConfirmationManager := stats.NewBlockConfirmationManager(database, waitGroup, node, blockConfirmationsRequired)
+	statsCollector := stats.NewCollector(database, waitGroup)
+	blockConfirmationManager := stats.NewBlockConfirmationManager(database, waitGroup, node, options.BlockConfirmationsRequired)
 
-	workmanager := gateway.NewWorkManager(workmanagerNotificationsBind, shareDifficulty, node, waitGroup)
+	workmanager := gateway.NewWorkManager(options.WorkmanagerNotificationsBind, options.NotificationAuth, options.ShareDifficulty, options.Vardiff, options.WorkPollInterval, options.WorkWatchdogTimeout, node, waitGroup)
+	if err := workmanager.RestoreBestShares(database); err != nil {
+		return nil, errors.Wrap(err, "unable to restore best shares")
+	}
 
-	webServer := web.NewServer(database, node, waitGroup, workmanager, webServerBind)
+	webServer := web.NewServer(database, node, waitGroup, workmanager, banManager, connLimiter, sessionRegistry, credentials, statsCollector, options.WebServerBind, options.WebServerAdminToken, options.WebServerCORSAllowedOrigins)
 
 	engine := MiningEngine{
+		Node:                         node,
 		Workmanager:                  workmanager,
-		workmanagerNotificationsBind: workmanagerNotificationsBind,
-		shareDifficulty:              shareDifficulty,
+		workmanagerNotificationsBind: options.WorkmanagerNotificationsBind,
+		workPollInterval:             options.WorkPollInterval,
+		shareDifficulty:              options.ShareDifficulty,
 		StatsCollector:               statsCollector,
+		BanManager:                   banManager,
 		BlockConfirmationManager:     blockConfirmationManager,
 		Database:                     database,
 		waitGroup:                    waitGroup,
 		WebServer:                    webServer,
-		webServerBind:                webServerBind,
+		webServerBind:                options.WebServerBind,
 	}
 
-	if insecureStratumBind != "" {
-		gatewayInsecure, err := gateway.NewGatewayInsecure(engine.Workmanager, insecureStratumBind, stratumPassword, engine.StatsCollector, waitGroup)
+	if options.GatewayInsecureBind != "" {
+		gatewayInsecure, err := gateway.NewGatewayInsecure(engine.Workmanager, options.GatewayInsecureBind, options.GatewayPassword, engine.StatsCollector, engine.BanManager, connLimiter, sessionRegistry, credentials, options.ProxyProtocol, waitGroup)
 		if err != nil {
 			return nil, errors.Wrap(err, "Unable to initialize insecure gateway")
 		}
 		engine.Gateways = append(engine.Gateways, &gatewayInsecure)
 	}
 
-	if secureStratumBind != "" {
-		return nil, errors.New("secure stratum is unimplemented")
+	if options.GatewaySecureBind != "" {
+		gatewaySecure, err := gateway.NewGatewaySecure(engine.Workmanager, options.GatewaySecureBind, options.GatewayTLSCertFile, options.GatewayTLSKeyFile, options.GatewayPassword, engine.StatsCollector, engine.BanManager, connLimiter, sessionRegistry, credentials, options.ProxyProtocol, waitGroup)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to initialize secure gateway")
+		}
+		engine.Gateways = append(engine.Gateways, &gatewaySecure)
 	}
 
 	return &engine, nil
@@ -98,16 +164,21 @@ func NewMiningEngine(workmanagerNotificationsBind string, shareDifficulty uint64
 
 // Start starts the mining engine
 func (e *MiningEngine) Start() {
+	go e.Node.Run()
+
 	// Starting work manager
 	go e.Workmanager.Run()
 
 	log.Logger.WithFields(logrus.Fields{
 		"prefix":             "engine",
 		"notifications-bind": e.workmanagerNotificationsBind,
+		"poll-interval":      e.workPollInterval,
 	}).Info("Started Work Manager")
 
 	go e.StatsCollector.Run()
 
+	go e.BanManager.Run()
+
 	for _, g := range e.Gateways {
 		go g.Run()
 	}
@@ -123,6 +194,7 @@ func (e *MiningEngine) Start() {
 	log.Logger.WithFields(logrus.Fields{
 		"prefix":     "engine",
 		"share-diff": humanize.SIWithDigits(float64(e.shareDifficulty), 2, "H"),
+		"vardiff":    e.Workmanager.VardiffEnabled(),
 	}).Info("Started mining engine")
 }
 
@@ -131,10 +203,16 @@ func (e *MiningEngine) Stop() {
 	for _, g := range e.Gateways {
 		g.Stop()
 	}
+	// The in-flight shares must be accounted before the rest of the engine stops
+	for _, g := range e.Gateways {
+		g.Wait()
+	}
 	e.Workmanager.Stop()
+	e.BanManager.Stop()
 	e.StatsCollector.Stop()
 	e.BlockConfirmationManager.Stop()
 	e.WebServer.Stop()
+	e.Node.Stop()
 
 	e.waitGroup.Wait()
 	e.Database.DB.Close()
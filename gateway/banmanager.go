@@ -0,0 +1,294 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flexpool/solo/db"
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/utils"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// BanOptions specifies the gateway ban manager parameters
+type BanOptions struct {
+	Enabled              bool
+	MaxFailedLogins      uint64
+	MaxMalformedRequests uint64
+	MaxInvalidShareRatio float64
+	MinSharesForRatio    uint64        // Invalid share ratio is checked only after that many shares
+	Window               time.Duration // Offense counters are reset after the window
+	Duration             time.Duration // First ban duration, doubled for every next offense
+	MaxDuration          time.Duration
+	ExemptNetworks       []*net.IPNet
+}
+
+// banCounters holds the offenses of the IP address within the current window
+type banCounters struct {
+	windowStart       time.Time
+	failedLogins      uint64
+	malformedRequests uint64
+	shares            uint64
+	invalidShares     uint64
+}
+
+// BanManager counts the misbehaviour of the IP addresses, and bans them with escalating durations
+type BanManager struct {
+	options           BanOptions
+	database          *db.Database
+	bans              map[string]db.Ban
+	counters          map[string]*banCounters
+	mux               sync.Mutex
+	engineWaitGroup   *sync.WaitGroup
+	context           context.Context
+	cancelContextFunc context.CancelFunc
+}
+
+// NewBanManager creates a new BanManager instance, and loads the persisted bans
+func NewBanManager(options BanOptions, database *db.Database, engineWaitGroup *sync.WaitGroup) (*BanManager, error) {
+	bans, err := database.GetBans()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load bans")
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	banManager := BanManager{
+		options:           options,
+		database:          database,
+		bans:              make(map[string]db.Ban),
+		counters:          make(map[string]*banCounters),
+		engineWaitGroup:   engineWaitGroup,
+		context:           ctx,
+		cancelContextFunc: cancelFunc,
+	}
+
+	for _, ban := range bans {
+		banManager.bans[ban.IPAddress] = ban
+	}
+
+	return &banManager, nil
+}
+
+// IsBanned returns true if the IP address is currently banned
+func (b *BanManager) IsBanned(ip string) bool {
+	if !b.options.Enabled {
+		return false
+	}
+
+	b.mux.Lock()
+	ban, ok := b.bans[ip]
+	b.mux.Unlock()
+
+	return ok && ban.ExpiresAt > time.Now().Unix()
+}
+
+// getCounters returns the current window counters of the IP address (nil if the IP is exempt). Must be called with the lock held.
+func (b *BanManager) getCounters(ip string) *banCounters {
+	if !b.options.Enabled || utils.IPInNetworks(ip, b.options.ExemptNetworks) {
+		return nil
+	}
+
+	counters, ok := b.counters[ip]
+	if !ok || time.Since(counters.windowStart) > b.options.Window {
+		counters = &banCounters{windowStart: time.Now()}
+		b.counters[ip] = counters
+	}
+
+	return counters
+}
+
+// RecordFailedLogin counts the failed login, and bans the IP if there are too many of them
+func (b *BanManager) RecordFailedLogin(ip string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if counters := b.getCounters(ip); counters != nil {
+		counters.failedLogins++
+		if counters.failedLogins >= b.options.MaxFailedLogins {
+			b.ban(ip, "too many failed logins")
+		}
+	}
+}
+
+// RecordMalformedRequest counts the malformed JSON-RPC request, and bans the IP if there are too many of them
+func (b *BanManager) RecordMalformedRequest(ip string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if counters := b.getCounters(ip); counters != nil {
+		counters.malformedRequests++
+		if counters.malformedRequests >= b.options.MaxMalformedRequests {
+			b.ban(ip, "too many malformed requests")
+		}
+	}
+}
+
+// RecordShare counts the submitted share, and bans the IP if the ratio of the invalid ones is too high
+func (b *BanManager) RecordShare(ip string, invalid bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if counters := b.getCounters(ip); counters != nil {
+		counters.shares++
+		if invalid {
+			counters.invalidShares++
+		}
+
+		if counters.shares >= b.options.MinSharesForRatio && float64(counters.invalidShares)/float64(counters.shares) > b.options.MaxInvalidShareRatio {
+			b.ban(ip, "too many invalid shares")
+		}
+	}
+}
+
+// ban bans the IP address, doubling the duration for every previous offense. Must be called with the lock held.
+func (b *BanManager) ban(ip string, reason string) {
+	offenses := b.bans[ip].Offenses + 1
+
+	duration := b.options.Duration
+	for i := uint64(1); i < offenses && duration < b.options.MaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > b.options.MaxDuration {
+		duration = b.options.MaxDuration
+	}
+
+	now := time.Now()
+	ban := db.Ban{
+		IPAddress: ip,
+		Reason:    reason,
+		Offenses:  offenses,
+		BannedAt:  now.Unix(),
+		ExpiresAt: now.Add(duration).Unix(),
+	}
+
+	b.bans[ip] = ban
+	delete(b.counters, ip)
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":   "gateway",
+		"ip":       ip,
+		"reason":   reason,
+		"offenses": offenses,
+		"duration": duration,
+	}).Warn("Banned IP address")
+
+	if err := b.database.WriteBan(ban); err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "gateway",
+			"ip":     ip,
+			"error":  err,
+		}).Error("Unable to write ban to DB")
+	}
+}
+
+// Bans returns the active bans, sorted by the expiration time
+func (b *BanManager) Bans() []db.Ban {
+	now := time.Now().Unix()
+	bans := []db.Ban{}
+
+	b.mux.Lock()
+	for _, ban := range b.bans {
+		if ban.ExpiresAt > now {
+			bans = append(bans, ban)
+		}
+	}
+	b.mux.Unlock()
+
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].ExpiresAt < bans[j].ExpiresAt
+	})
+
+	return bans
+}
+
+// Unban removes the ban (and the offense history) of the IP address. Returns false if the IP is not banned.
+func (b *BanManager) Unban(ip string) (bool, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if _, ok := b.bans[ip]; !ok {
+		return false, nil
+	}
+
+	delete(b.bans, ip)
+	delete(b.counters, ip)
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix": "gateway",
+		"ip":     ip,
+	}).Info("Unbanned IP address")
+
+	return true, b.database.DeleteBan(ip)
+}
+
+// prune removes the expired counters, and forgets the offenses of the IPs whose bans have expired long ago
+func (b *BanManager) prune() {
+	now := time.Now()
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for ip, counters := range b.counters {
+		if now.Sub(counters.windowStart) > b.options.Window {
+			delete(b.counters, ip)
+		}
+	}
+
+	for ip, ban := range b.bans {
+		if time.Unix(ban.ExpiresAt, 0).Add(b.options.MaxDuration).Before(now) {
+			delete(b.bans, ip)
+			if err := b.database.DeleteBan(ip); err != nil {
+				log.Logger.WithFields(logrus.Fields{
+					"prefix": "gateway",
+					"ip":     ip,
+					"error":  err,
+				}).Error("Unable to delete expired ban from DB")
+			}
+		}
+	}
+}
+
+// Run runs the ban manager garbage collector
+func (b *BanManager) Run() {
+	b.engineWaitGroup.Add(1)
+	defer b.engineWaitGroup.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.context.Done():
+			return
+		case <-ticker.C:
+			b.prune()
+		}
+	}
+}
+
+// Stop stops the ban manager
+func (b *BanManager) Stop() {
+	b.cancelContextFunc()
+}
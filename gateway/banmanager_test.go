@@ -0,0 +1,172 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flexpool/solo/db"
+)
+
+func newTestBanManager(t *testing.T, options BanOptions) *BanManager {
+	database, err := db.OpenDB(t.TempDir(), db.StatsOptions{CollectionPeriodSecs: 600, RetentionSecs: 86400})
+	if err != nil {
+		t.Fatalf("unable to open the database: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+
+	banManager, err := NewBanManager(options, database, &sync.WaitGroup{})
+	if err != nil {
+		t.Fatalf("unable to create the ban manager: %v", err)
+	}
+	return banManager
+}
+
+var testBanOptions = BanOptions{
+	Enabled:              true,
+	MaxFailedLogins:      3,
+	MaxMalformedRequests: 3,
+	MaxInvalidShareRatio: 0.5,
+	MinSharesForRatio:    4,
+	Window:               time.Minute,
+	Duration:             time.Minute,
+	MaxDuration:          3 * time.Minute,
+}
+
+func TestBanManagerEscalation(t *testing.T) {
+	banManager := newTestBanManager(t, testBanOptions)
+	const ip = "192.0.2.1"
+
+	for offense, wantDuration := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		for i := uint64(1); i < testBanOptions.MaxFailedLogins; i++ {
+			banManager.RecordFailedLogin(ip)
+		}
+		if offenses := banManager.bans[ip].Offenses; offenses != uint64(offense) {
+			t.Fatalf("banned before reaching the failed login limit (offenses %d)", offenses)
+		}
+
+		banManager.RecordFailedLogin(ip)
+		if !banManager.IsBanned(ip) {
+			t.Fatal("expected the IP to be banned")
+		}
+
+		ban := banManager.bans[ip]
+		if duration := time.Duration(ban.ExpiresAt-ban.BannedAt) * time.Second; duration != wantDuration {
+			t.Fatalf("offense %d: expected ban duration %v, got %v", ban.Offenses, wantDuration, duration)
+		}
+	}
+
+	persisted, err := banManager.database.GetBans()
+	if err != nil {
+		t.Fatalf("unable to get the persisted bans: %v", err)
+	}
+	if len(persisted) != 1 || persisted[0].Offenses != 4 {
+		t.Fatalf("expected one persisted ban with 4 offenses, got %+v", persisted)
+	}
+
+	if ok, err := banManager.Unban(ip); !ok || err != nil {
+		t.Fatalf("expected the IP to be unbanned, got %v (%v)", ok, err)
+	}
+	if banManager.IsBanned(ip) {
+		t.Fatal("expected the IP not to be banned after the unban")
+	}
+}
+
+func TestBanManagerTriggers(t *testing.T) {
+	_, exemptNetwork, _ := net.ParseCIDR("198.51.100.0/24")
+	options := testBanOptions
+	options.ExemptNetworks = []*net.IPNet{exemptNetwork}
+
+	tests := []struct {
+		name       string
+		ip         string
+		record     func(b *BanManager, ip string, i int)
+		records    int
+		wantBanned bool
+	}{
+		{"malformed requests", "192.0.2.1", func(b *BanManager, ip string, i int) { b.RecordMalformedRequest(ip) }, 3, true},
+		{"too few malformed requests", "192.0.2.1", func(b *BanManager, ip string, i int) { b.RecordMalformedRequest(ip) }, 2, false},
+		{"invalid share ratio", "192.0.2.1", func(b *BanManager, ip string, i int) { b.RecordShare(ip, i%4 != 0) }, 4, true},
+		{"acceptable share ratio", "192.0.2.1", func(b *BanManager, ip string, i int) { b.RecordShare(ip, i%2 == 1) }, 8, false},
+		{"too few shares for the ratio", "192.0.2.1", func(b *BanManager, ip string, i int) { b.RecordShare(ip, true) }, 3, false},
+		{"exempt network", "198.51.100.7", func(b *BanManager, ip string, i int) { b.RecordFailedLogin(ip) }, 10, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			banManager := newTestBanManager(t, options)
+			for i := 0; i < test.records; i++ {
+				test.record(banManager, test.ip, i)
+			}
+			if banned := banManager.IsBanned(test.ip); banned != test.wantBanned {
+				t.Fatalf("expected banned %v, got %v", test.wantBanned, banned)
+			}
+		})
+	}
+}
+
+func TestBanManagerPrune(t *testing.T) {
+	banManager := newTestBanManager(t, testBanOptions)
+	now := time.Now()
+
+	bans := []db.Ban{
+		{IPAddress: "192.0.2.1", Offenses: 1, BannedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()},                        // Active
+		{IPAddress: "192.0.2.2", Offenses: 2, BannedAt: now.Add(-2 * time.Minute).Unix(), ExpiresAt: now.Add(-time.Minute).Unix()}, // Expired recently, offenses remembered
+		{IPAddress: "192.0.2.3", Offenses: 3, BannedAt: now.Add(-time.Hour).Unix(), ExpiresAt: now.Add(-time.Hour).Unix()},         // Expired long ago
+	}
+	for _, ban := range bans {
+		banManager.bans[ban.IPAddress] = ban
+		if err := banManager.database.WriteBan(ban); err != nil {
+			t.Fatalf("unable to write the ban: %v", err)
+		}
+	}
+
+	banManager.counters["192.0.2.4"] = &banCounters{windowStart: now, failedLogins: 1}
+	banManager.counters["192.0.2.5"] = &banCounters{windowStart: now.Add(-2 * time.Minute), failedLogins: 1}
+
+	banManager.prune()
+
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		if _, ok := banManager.bans[ip]; !ok {
+			t.Errorf("expected the ban of %s to be kept", ip)
+		}
+	}
+	if _, ok := banManager.bans["192.0.2.3"]; ok {
+		t.Error("expected the long expired ban to be pruned")
+	}
+	if _, ok := banManager.counters["192.0.2.4"]; !ok {
+		t.Error("expected the current counters to be kept")
+	}
+	if _, ok := banManager.counters["192.0.2.5"]; ok {
+		t.Error("expected the expired counters to be pruned")
+	}
+
+	persisted, err := banManager.database.GetBans()
+	if err != nil {
+		t.Fatalf("unable to get the persisted bans: %v", err)
+	}
+	if len(persisted) != 2 {
+		t.Fatalf("expected 2 persisted bans after the prune, got %d", len(persisted))
+	}
+
+	if active := banManager.Bans(); len(active) != 1 || active[0].IPAddress != "192.0.2.1" {
+		t.Fatalf("expected only 192.0.2.1 to be actively banned, got %+v", active)
+	}
+}
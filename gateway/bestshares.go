@@ -0,0 +1,117 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/flexpool/solo/db"
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// roundBestShares tracks the best shares of the current round, overall and per worker (lower target is better)
+type roundBestShares struct {
+	roundStart        int64
+	bestTarget        *big.Int
+	workerBestTargets map[string]*big.Int
+	mux               sync.Mutex
+}
+
+func newRoundBestShares(roundStart int64) *roundBestShares {
+	return &roundBestShares{
+		roundStart:        roundStart,
+		workerBestTargets: make(map[string]*big.Int),
+	}
+}
+
+// record returns whether the share is the best of the round, and the best of the worker within the round
+func (r *roundBestShares) record(workerName string, target *big.Int) (roundBest bool, workerBest bool, roundStart int64) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if workerBestTarget, ok := r.workerBestTargets[workerName]; !ok || workerBestTarget.Cmp(target) == 1 {
+		r.workerBestTargets[workerName] = target
+		workerBest = true
+	}
+
+	if r.bestTarget == nil || r.bestTarget.Cmp(target) == 1 {
+		r.bestTarget = target
+		roundBest = true
+	}
+
+	return roundBest, workerBest, r.roundStart
+}
+
+// newRound resets the best shares for the round starting at roundStart, unless a later round has already started
+func (r *roundBestShares) newRound(roundStart int64) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if roundStart < r.roundStart {
+		return
+	}
+
+	r.roundStart = roundStart
+	r.bestTarget = nil
+	r.workerBestTargets = make(map[string]*big.Int)
+}
+
+// RestoreBestShares restores the current round and its best shares from the database
+func (w *WorkManager) RestoreBestShares(database *db.Database) error {
+	roundStart, err := database.GetCurrentRoundStart()
+	if err == leveldb.ErrNotFound {
+		// The current round started with the latest mined block (or now, if there's none)
+		roundStart = time.Now().Unix()
+		latestBlocks, err := database.GetBlocks(db.BlockQuery{SortByTime: true, Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(latestBlocks.Blocks) > 0 {
+			roundStart = latestBlocks.Blocks[0].Timestamp
+		}
+
+		if err := database.StartRound(roundStart); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	bestShares := newRoundBestShares(roundStart)
+	for _, bestShare := range database.GetRoundBestShares(roundStart) {
+		if bestShare.ActualShareDifficulty <= 0 {
+			continue
+		}
+		target, _ := big.NewFloat(0).Quo(big.NewFloat(0).SetInt(utils.BigMax256bit), big.NewFloat(bestShare.ActualShareDifficulty)).Int(nil)
+		bestShares.record(bestShare.WorkerName, target)
+	}
+	w.bestShares = bestShares
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":      "workmanager",
+		"round-start": roundStart,
+		"workers":     len(bestShares.workerBestTargets),
+	}).Info("Restored current round best shares")
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionLimits specifies the gateway connection limits (zero values mean unlimited/disabled)
+type ConnectionLimits struct {
+	MaxConnections      uint64
+	MaxConnectionsPerIP uint64
+	IdleTimeout         time.Duration // Authenticated sessions are closed after this time without submits or getWork requests
+	KeepAlivePeriod     time.Duration
+	ShutdownGracePeriod time.Duration // On shutdown, the open sessions are given this time to finish their in-flight requests
+}
+
+// ConnectionLimiter tracks the active connections of all gateways, and rejects the ones exceeding the limits
+type ConnectionLimiter struct {
+	limits   ConnectionLimits
+	perIP    map[string]uint64
+	active   uint64
+	rejected uint64
+	mux      sync.Mutex
+}
+
+// NewConnectionLimiter creates a new ConnectionLimiter instance
+func NewConnectionLimiter(limits ConnectionLimits) *ConnectionLimiter {
+	return &ConnectionLimiter{
+		limits: limits,
+		perIP:  make(map[string]uint64),
+	}
+}
+
+// acquire registers the new connection of the IP, and returns false if it exceeds the limits
+func (l *ConnectionLimiter) acquire(ip string) bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if (l.limits.MaxConnections != 0 && l.active >= l.limits.MaxConnections) ||
+		(l.limits.MaxConnectionsPerIP != 0 && l.perIP[ip] >= l.limits.MaxConnectionsPerIP) {
+		atomic.AddUint64(&l.rejected, 1)
+		return false
+	}
+
+	l.perIP[ip]++
+	atomic.AddUint64(&l.active, 1)
+	return true
+}
+
+// release unregisters the closed connection of the IP
+func (l *ConnectionLimiter) release(ip string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.perIP[ip]--
+	if l.perIP[ip] == 0 {
+		delete(l.perIP, ip)
+	}
+	atomic.AddUint64(&l.active, ^uint64(0))
+}
+
+// ActiveSessions returns the number of the currently open gateway connections
+func (l *ConnectionLimiter) ActiveSessions() uint64 {
+	return atomic.LoadUint64(&l.active)
+}
+
+// RejectedSessions returns the number of the connections rejected due to the limits
+func (l *ConnectionLimiter) RejectedSessions() uint64 {
+	return atomic.LoadUint64(&l.rejected)
+}
+
+// trackedConn releases its slot in the ConnectionLimiter when closed
+type trackedConn struct {
+	net.Conn
+	limiter   *ConnectionLimiter
+	ip        string
+	closeOnce sync.Once
+}
+
+// Close closes the connection, and releases its slot
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.limiter.release(c.ip)
+	})
+	return err
+}
+
+// refreshIdleDeadline extends the session's read deadline by the idle timeout (or removes it if the timeout is disabled)
+func (g *Gateway) refreshIdleDeadline(conn net.Conn) {
+	// Draining sessions must not be extended
+	if g.context.Err() != nil {
+		conn.SetReadDeadline(time.Now())
+		return
+	}
+
+	if g.connLimiter.limits.IdleTimeout == 0 {
+		conn.SetReadDeadline(time.Time{})
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(g.connLimiter.limits.IdleTimeout))
+}
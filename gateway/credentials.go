@@ -0,0 +1,222 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"crypto/sha256"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/flexpool/solo/db"
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/utils"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// verifiedPasswords caches the successful bcrypt verifications, since getwork miners authenticate every request
+var verifiedPasswords = struct {
+	sync.Mutex
+	m map[[32]byte]bool
+}{m: make(map[[32]byte]bool)}
+
+// checkPasswordHash compares the password with the bcrypt hash
+func checkPasswordHash(passwordHash string, password string) bool {
+	key := sha256.Sum256([]byte(passwordHash + "\x00" + password))
+
+	verifiedPasswords.Lock()
+	ok := verifiedPasswords.m[key]
+	verifiedPasswords.Unlock()
+	if ok {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		return false
+	}
+
+	verifiedPasswords.Lock()
+	verifiedPasswords.m[key] = true
+	verifiedPasswords.Unlock()
+	return true
+}
+
+// CredentialStore keeps the worker credentials in memory, so the logins don't have to read the database
+type CredentialStore struct {
+	database    *db.Database
+	credentials []db.WorkerCredential // Sorted by the name pattern, like in the database
+	mux         sync.RWMutex
+}
+
+// NewCredentialStore creates a new CredentialStore instance, and loads the credentials from the database
+func NewCredentialStore(database *db.Database) (*CredentialStore, error) {
+	credentials, err := database.GetWorkerCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CredentialStore{database: database, credentials: credentials}, nil
+}
+
+// Credentials returns all worker credentials
+func (s *CredentialStore) Credentials() []db.WorkerCredential {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	credentials := make([]db.WorkerCredential, len(s.credentials))
+	copy(credentials, s.credentials)
+	return credentials
+}
+
+// Credential returns the worker credential by its name pattern
+func (s *CredentialStore) Credential(namePattern string) (db.WorkerCredential, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, credential := range s.credentials {
+		if credential.NamePattern == namePattern {
+			return credential, true
+		}
+	}
+	return db.WorkerCredential{}, false
+}
+
+// Write writes (or replaces) the worker credential
+func (s *CredentialStore) Write(credential db.WorkerCredential) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.database.WriteWorkerCredential(credential); err != nil {
+		return err
+	}
+
+	i := sort.Search(len(s.credentials), func(i int) bool { return s.credentials[i].NamePattern >= credential.NamePattern })
+	if i < len(s.credentials) && s.credentials[i].NamePattern == credential.NamePattern {
+		s.credentials[i] = credential
+		return nil
+	}
+
+	s.credentials = append(s.credentials, db.WorkerCredential{})
+	copy(s.credentials[i+1:], s.credentials[i:])
+	s.credentials[i] = credential
+	return nil
+}
+
+// Delete removes the worker credential (returns leveldb.ErrNotFound if there is no such credential)
+func (s *CredentialStore) Delete(namePattern string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.database.DeleteWorkerCredential(namePattern); err != nil {
+		return err
+	}
+
+	for i, credential := range s.credentials {
+		if credential.NamePattern == namePattern {
+			s.credentials = append(s.credentials[:i], s.credentials[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Governs returns whether the worker is authenticated with the credential of the name pattern
+func (s *CredentialStore) Governs(namePattern string, workerName string) bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	credential, ok := findWorkerCredential(s.credentials, workerName)
+	return ok && credential.NamePattern == namePattern
+}
+
+// find returns the most specific credential matching the worker name
+func (s *CredentialStore) find(workerName string) (db.WorkerCredential, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return findWorkerCredential(s.credentials, workerName)
+}
+
+// findWorkerCredential returns the most specific credential matching the worker name (exact name first, then the longest pattern)
+func findWorkerCredential(credentials []db.WorkerCredential, workerName string) (db.WorkerCredential, bool) {
+	var best db.WorkerCredential
+	var found bool
+
+	for _, credential := range credentials {
+		if credential.NamePattern == workerName {
+			return credential, true
+		}
+
+		if matched, _ := path.Match(credential.NamePattern, workerName); matched && len(credential.NamePattern) > len(best.NamePattern) {
+			best = credential
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// checkCredentials checks the worker credentials, and counts the failed logins of the IP
+func (g *Gateway) checkCredentials(workerName string, password string, ip string) bool {
+	if !g.verifyCredentials(workerName, password, ip) {
+		g.banManager.RecordFailedLogin(ip)
+		return false
+	}
+
+	return true
+}
+
+// verifyCredentials verifies the worker credentials. Workers that are not in the credentials store use the shared gateway password.
+func (g *Gateway) verifyCredentials(workerName string, password string, ip string) bool {
+	logger := log.Logger.WithFields(logrus.Fields{
+		"prefix":      "gateway",
+		"worker-name": workerName,
+		"ip":          ip,
+	})
+
+	credential, ok := g.credentials.find(workerName)
+	if !ok {
+		if g.stratumPassword == "" || password != g.stratumPassword {
+			logger.Warn("Invalid password")
+			return false
+		}
+
+		return true
+	}
+
+	if !credential.Enabled {
+		logger.WithField("name-pattern", credential.NamePattern).Warn("Worker is disabled")
+		return false
+	}
+
+	if len(credential.AllowedIPs) != 0 {
+		allowedNetworks, err := utils.ParseIPNetworks(credential.AllowedIPs)
+		if err != nil || !utils.IPInNetworks(ip, allowedNetworks) {
+			logger.WithField("name-pattern", credential.NamePattern).Warn("Worker IP is not allowed")
+			return false
+		}
+	}
+
+	if !checkPasswordHash(credential.PasswordHash, password) {
+		logger.WithField("name-pattern", credential.NamePattern).Warn("Invalid password")
+		return false
+	}
+
+	return true
+}
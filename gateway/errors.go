@@ -52,6 +52,26 @@ func GetNotRequestedWorkError(id int) []byte {
 	return marshalError(id, "Work is outdated, or not requested", nil)
 }
 
+// GetNotSubscribedError creates and restart Stratum `Not subscribed` message
+func GetNotSubscribedError(id int) []byte {
+	return marshalError(id, "Not subscribed", nil)
+}
+
+// GetExtraNoncesExhaustedError creates and returns Stratum `No free extranonce available` message
+func GetExtraNoncesExhaustedError(id int) []byte {
+	return marshalError(id, "No free extranonce available", nil)
+}
+
+// GetNodeUnhealthyError creates and returns Stratum `Node is syncing or unhealthy, no work available` message
+func GetNodeUnhealthyError(id int) []byte {
+	return marshalError(id, "Node is syncing or unhealthy, no work available", nil)
+}
+
+// GetDuplicateShareError creates and returns Stratum `Duplicate share` message
+func GetDuplicateShareError(id int) []byte {
+	return marshalError(id, "Duplicate share", false)
+}
+
 // GetInvalidShareError creates and restart Stratum `Provided POW solution is invalid` message
 func GetInvalidShareError(id int) []byte {
 	return marshalError(id, "Provided POW solution is invalid", false)
@@ -0,0 +1,301 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/flexpool/solo/jsonrpc"
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/types"
+	"github.com/flexpool/solo/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// EthereumStratumVersion is the protocol version string sent in mining.subscribe
+const EthereumStratumVersion = "EthereumStratum/1.0.0"
+
+const extraNonceSize = 2                // Extranonce size in bytes
+const ethereumStratumDiff1 = 4294967296 // EthereumStratum difficulty 1 equals to 2^32 hashes
+
+func (g *Gateway) marshalEthereumStratumWork(work []string) []byte {
+	jobID, _ := g.parentWorkManager.workHistory.GetJobID(work[0])
+	return jsonrpc.MarshalNotification(jsonrpc.Notification{
+		JSONRPCVersion: jsonrpc.Version,
+		Method:         "mining.notify",
+		Params:         []interface{}{jobID, utils.Clear0x(work[1]), utils.Clear0x(work[0]), true},
+	})
+}
+
+func marshalEthereumStratumDifficulty(difficulty uint64) []byte {
+	return jsonrpc.MarshalNotification(jsonrpc.Notification{
+		JSONRPCVersion: jsonrpc.Version,
+		Method:         "mining.set_difficulty",
+		Params:         []interface{}{float64(difficulty) / ethereumStratumDiff1},
+	})
+}
+
+// HandleEthereumStratumConnection handles the gateway connection speaking EthereumStratum/1.0.0 (NiceHash) protocol
+func (g *Gateway) HandleEthereumStratumConnection(conn net.Conn) {
+	defer conn.Close()
+
+	// Stops the work sender
+	sessionDone := make(chan struct{})
+	defer close(sessionDone)
+
+	var session *Session
+	defer func() { g.sessionRegistry.unregister(session) }()
+
+	// Miner version is reported by the mining.subscribe request
+	var userAgent string
+
+	// Add 5 sec timeout
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	scanner := bufio.NewScanner(conn)
+
+	var subscribed bool
+	var authenticated bool
+
+	var workerName string
+	var extraNonce string
+	defer func() {
+		if subscribed {
+			g.parentWorkManager.ReleaseExtraNonce(extraNonce)
+		}
+	}()
+
+	ip := getIPAddress(conn)
+
+	shareDifficulty := g.parentWorkManager.newVardiff()
+
+	for scanner.Scan() {
+		// The IP might have been banned by this, or by any other connection
+		if g.banManager.IsBanned(ip) {
+			return
+		}
+
+		request, err := jsonrpc.UnmarshalRequest(scanner.Bytes())
+		if err != nil {
+			write(conn, GetInvalidRequestError(0))
+			log.Logger.WithFields(logrus.Fields{
+				"prefix": "gateway",
+				"ip":     ip,
+			}).Warn("Invalid JSONRPC request")
+			g.banManager.RecordMalformedRequest(ip)
+
+			// Close connection if not authenticated
+			if !authenticated {
+				return
+			}
+			continue
+		}
+
+		switch request.Method {
+		case "mining.subscribe":
+			if len(request.Params) > 1 && request.Params[1] != EthereumStratumVersion {
+				write(conn, GetInvalidParamsError(request.ID))
+				return
+			}
+
+			if !subscribed {
+				var ok bool
+				extraNonce, ok = g.parentWorkManager.NextExtraNonce()
+				if !ok {
+					log.Logger.WithFields(logrus.Fields{
+						"prefix": "gateway",
+						"ip":     ip,
+					}).Warn("All extranonces are taken, rejecting the session")
+					write(conn, GetExtraNoncesExhaustedError(request.ID))
+					return
+				}
+				subscribed = true
+			}
+
+			if len(request.Params) > 0 {
+				userAgent = request.Params[0]
+			}
+
+			write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
+				JSONRPCVersion: jsonrpc.Version,
+				ID:             request.ID,
+				Result:         []interface{}{[]string{"mining.notify", extraNonce, EthereumStratumVersion}, extraNonce},
+				Error:          nil,
+			}))
+		case "mining.extranonce.subscribe":
+			// Extranonce is never changed during the session
+			write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
+				JSONRPCVersion: jsonrpc.Version,
+				ID:             request.ID,
+				Result:         true,
+				Error:          nil,
+			}))
+		case "mining.authorize":
+			if !subscribed {
+				write(conn, GetNotSubscribedError(request.ID))
+				return
+			}
+
+			if authenticated {
+				write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
+					JSONRPCVersion: jsonrpc.Version,
+					ID:             request.ID,
+					Result:         true,
+					Error:          nil,
+				}))
+				continue
+			}
+
+			if len(request.Params) < 2 {
+				write(conn, GetInvalidCredentialsError(request.ID))
+				return
+			}
+
+			workerName = request.Params[0]
+
+			if !g.authenticate(workerName, request.Params[1], ip, types.EthereumStratumProtocol) {
+				write(conn, GetInvalidCredentialsError(request.ID))
+				return
+			}
+
+			write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
+				JSONRPCVersion: jsonrpc.Version,
+				ID:             request.ID,
+				Result:         true,
+				Error:          nil,
+			}))
+
+			authenticated = true
+			session = g.sessionRegistry.register(conn, workerName, types.EthereumStratumProtocol, userAgent, shareDifficulty)
+
+			// Replacing the login timeout with the idle one
+			g.refreshIdleDeadline(conn)
+
+			write(conn, marshalEthereumStratumDifficulty(shareDifficulty.Difficulty()))
+			// While the node is unhealthy, the first job is sent as soon as it recovers
+			if g.parentWorkManager.Node.Healthy() {
+				write(conn, g.marshalEthereumStratumWork(shareDifficulty.applyToWork(g.parentWorkManager.GetLastWork(false))))
+			}
+
+			// Starting work sender
+			go g.RunWorkSender(conn, sessionDone, func(work []string) []byte {
+				if shareDifficulty.retarget() {
+					// New difficulty should be sent before the job it is applied to
+					logDifficultyChange(workerName, ip, shareDifficulty.Difficulty())
+					notification := g.marshalEthereumStratumWork(shareDifficulty.applyToWork(work))
+					return append(append(marshalEthereumStratumDifficulty(shareDifficulty.Difficulty()), '\n'), notification...)
+				}
+				return g.marshalEthereumStratumWork(shareDifficulty.applyToWork(work))
+			})
+		case "mining.submit":
+			if !authenticated {
+				write(conn, GetUnauthorizedError(request.ID))
+				return
+			}
+
+			g.refreshIdleDeadline(conn)
+
+			if len(request.Params) < 3 || len(extraNonce)+len(request.Params[2]) != 16 {
+				write(conn, GetInvalidParamsError(request.ID))
+				continue
+			}
+
+			nonce, err := strconv.ParseUint(extraNonce+request.Params[2], 16, 64)
+			if err != nil {
+				write(conn, GetInvalidParamsError(request.ID))
+				continue
+			}
+
+			work, ok := g.parentWorkManager.workHistory.GetByJobID(request.Params[1])
+			if !ok {
+				write(conn, GetNotRequestedWorkError(request.ID))
+				continue
+			}
+
+			headerHash, _ := hex.DecodeString(utils.Clear0x(work[0]))
+			mixDigest := computeMixDigest(headerHash, nonce, utils.MustSoftHexToUint64(work[3]))
+
+			jobDifficulty := shareDifficulty.jobDifficulty(work[0])
+			shareType, err := g.submitShare([]string{"0x" + extraNonce + request.Params[2], work[0], "0x" + hex.EncodeToString(mixDigest)}, workerName, jobDifficulty)
+			if err != nil {
+				write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
+					JSONRPCVersion: jsonrpc.Version,
+					ID:             request.ID,
+					Result:         nil,
+					Error:          err.Error(),
+				}))
+				continue
+			}
+
+			if shareType == types.ShareValid || shareType == types.ShareStale {
+				write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
+					JSONRPCVersion: jsonrpc.Version,
+					ID:             request.ID,
+					Result:         true,
+					Error:          nil,
+				}))
+			} else if shareType == types.ShareDuplicate {
+				write(conn, GetDuplicateShareError(request.ID))
+			} else {
+				write(conn, GetInvalidShareError(request.ID))
+			}
+
+			g.accountShare(workerName, ip, shareType, jobDifficulty)
+			g.sessionRegistry.recordShare(session)
+
+			if (shareType == types.ShareValid || shareType == types.ShareStale) && shareDifficulty.registerShare() {
+				// The new difficulty is applied starting from the next job, so the current one is resent
+				logDifficultyChange(workerName, ip, shareDifficulty.Difficulty())
+				write(conn, marshalEthereumStratumDifficulty(shareDifficulty.Difficulty()))
+				write(conn, g.marshalEthereumStratumWork(shareDifficulty.applyToWork(g.parentWorkManager.GetLastWork(false))))
+			}
+		case "eth_submitHashrate":
+			// Some EthereumStratum miners still report hashrate (in hex) along with the stratum session
+			if !authenticated || len(request.Params) < 1 {
+				write(conn, GetInvalidParamsError(request.ID))
+				continue
+			}
+
+			g.refreshIdleDeadline(conn)
+
+			g.setReportedHashrate(workerName, utils.HexStrToBigInt(request.Params[0]))
+
+			write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
+				JSONRPCVersion: jsonrpc.Version,
+				ID:             request.ID,
+				Result:         true,
+				Error:          nil,
+			}))
+		default:
+			if !authenticated {
+				write(conn, GetUnauthorizedError(request.ID))
+				return
+			}
+
+			write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
+				JSONRPCVersion: jsonrpc.Version,
+				ID:             request.ID,
+				Result:         nil,
+				Error:          "Method not found",
+			}))
+		}
+	}
+}
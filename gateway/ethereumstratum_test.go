@@ -0,0 +1,40 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEthereumStratumDifficultyNotification(t *testing.T) {
+	var notification map[string]interface{}
+	if err := json.Unmarshal(marshalEthereumStratumDifficulty(ethereumStratumDiff1*2), &notification); err != nil {
+		t.Fatalf("unable to unmarshal the notification: %v", err)
+	}
+
+	// Server notifications have null ID, so they can't be taken for the response to the request 0
+	if id, ok := notification["id"]; !ok || id != nil {
+		t.Fatalf("expected null id, got %v", notification["id"])
+	}
+	if notification["method"] != "mining.set_difficulty" {
+		t.Fatalf("expected mining.set_difficulty method, got %v", notification["method"])
+	}
+	if params, ok := notification["params"].([]interface{}); !ok || len(params) != 1 || params[0] != float64(2) {
+		t.Fatalf("expected difficulty 2, got %v", notification["params"])
+	}
+}
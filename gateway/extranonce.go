@@ -0,0 +1,60 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"math"
+	"sync"
+)
+
+// extraNonceAllocator hands out the extranonces (nonce prefixes) of the EthereumStratum sessions,
+// so the live sessions never search the same nonce range
+type extraNonceAllocator struct {
+	inUse map[uint16]struct{}
+	next  uint16
+	mux   sync.Mutex
+}
+
+func newExtraNonceAllocator() *extraNonceAllocator {
+	return &extraNonceAllocator{inUse: make(map[uint16]struct{})}
+}
+
+// allocate returns the next free extranonce, or false if all of them are taken
+func (a *extraNonceAllocator) allocate() (uint16, bool) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if len(a.inUse) > math.MaxUint16 {
+		return 0, false
+	}
+
+	for {
+		extraNonce := a.next
+		a.next++ // Wraps around, the released extranonces are reused
+		if _, taken := a.inUse[extraNonce]; !taken {
+			a.inUse[extraNonce] = struct{}{}
+			return extraNonce, true
+		}
+	}
+}
+
+// release frees the extranonce of the closed session
+func (a *extraNonceAllocator) release(extraNonce uint16) {
+	a.mux.Lock()
+	delete(a.inUse, extraNonce)
+	a.mux.Unlock()
+}
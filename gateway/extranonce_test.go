@@ -0,0 +1,49 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExtraNonceAllocator(t *testing.T) {
+	allocator := newExtraNonceAllocator()
+
+	for i := 0; i <= math.MaxUint16; i++ {
+		extraNonce, ok := allocator.allocate()
+		if !ok || extraNonce != uint16(i) {
+			t.Fatalf("expected extranonce %d, got %d (%v)", i, extraNonce, ok)
+		}
+	}
+
+	if _, ok := allocator.allocate(); ok {
+		t.Fatal("expected the allocation to fail when all extranonces are taken")
+	}
+
+	// The released extranonces are reused, while the taken ones are skipped after the wrap around
+	allocator.release(7)
+	allocator.release(3)
+	for _, want := range []uint16{3, 7} {
+		if extraNonce, ok := allocator.allocate(); !ok || extraNonce != want {
+			t.Fatalf("expected extranonce %d, got %d (%v)", want, extraNonce, ok)
+		}
+	}
+	if _, ok := allocator.allocate(); ok {
+		t.Fatal("expected the allocation to fail when all extranonces are taken")
+	}
+}
@@ -0,0 +1,203 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/flexpool/solo/jsonrpc"
+	"github.com/flexpool/solo/types"
+	"github.com/flexpool/solo/utils"
+)
+
+// connListener is a net.Listener that accepts connections handed over by the protocol negotiation
+type connListener struct {
+	conns     chan net.Conn
+	addr      net.Addr
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{conns: make(chan net.Conn), addr: addr, closed: make(chan struct{})}
+}
+
+// Accept waits for and returns the next handed over connection
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errors.New("listener is closed")
+	}
+}
+
+// Close closes the listener
+func (l *connListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+// Addr returns the listener's network address
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
+
+// handOver passes the connection to the listener, or closes it if the listener is closed
+func (l *connListener) handOver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+// getworkCredentials extracts the worker name and password from the URL path (/<worker>[/<password>]) or Basic auth
+func getworkCredentials(r *http.Request) (string, string) {
+	pathSplitted := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 2)
+
+	workerName := pathSplitted[0]
+	var password string
+	if len(pathSplitted) > 1 {
+		password = pathSplitted[1]
+	}
+
+	if basicAuthUsername, basicAuthPassword, ok := r.BasicAuth(); ok {
+		if workerName == "" {
+			workerName = basicAuthUsername
+		}
+		if password == "" {
+			password = basicAuthPassword
+		}
+	}
+
+	return workerName, password
+}
+
+// ServeHTTP handles the HTTP getwork requests
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ip := getIPAddressFromString(r.RemoteAddr)
+
+	if g.banManager.IsBanned(ip) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	request, err := jsonrpc.UnmarshalRequest(data)
+	if err != nil {
+		g.banManager.RecordMalformedRequest(ip)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(GetInvalidRequestError(0))
+		return
+	}
+
+	workerName, password := getworkCredentials(r)
+	if workerName == "" || !g.checkCredentials(workerName, password, ip) {
+		w.Header().Set("WWW-Authenticate", "Basic realm=\"solo\"")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(GetInvalidCredentialsError(request.ID))
+		return
+	}
+
+	switch request.Method {
+	case "eth_getWork":
+		if !g.parentWorkManager.Node.Healthy() {
+			w.Write(GetNodeUnhealthyError(request.ID))
+			return
+		}
+
+		w.Write(jsonrpc.MarshalResponse(jsonrpc.Response{
+			JSONRPCVersion: jsonrpc.Version,
+			ID:             request.ID,
+			Result:         g.parentWorkManager.GetLastWork(true),
+			Error:          nil,
+		}))
+	case "eth_submitWork":
+		if len(request.Params) < 3 || len(request.Params[0]) != 18 || len(request.Params[1]) != 66 || len(request.Params[2]) != 66 {
+			w.Write(GetInvalidParamsError(request.ID))
+			return
+		}
+
+		// HTTP getwork is stateless, so the initial share difficulty is always used
+		shareType, err := g.submitShare(request.Params, workerName, g.parentWorkManager.shareDiff)
+		if err != nil {
+			w.Write(jsonrpc.MarshalResponse(jsonrpc.Response{
+				JSONRPCVersion: jsonrpc.Version,
+				ID:             request.ID,
+				Result:         nil,
+				Error:          err.Error(),
+			}))
+			return
+		}
+
+		if shareType == types.ShareValid || shareType == types.ShareStale {
+			w.Write(jsonrpc.MarshalResponse(jsonrpc.Response{
+				JSONRPCVersion: jsonrpc.Version,
+				ID:             request.ID,
+				Result:         true,
+				Error:          nil,
+			}))
+		} else if shareType == types.ShareDuplicate {
+			w.Write(GetDuplicateShareError(request.ID))
+		} else {
+			w.Write(GetInvalidShareError(request.ID))
+		}
+
+		g.accountShare(workerName, ip, shareType, g.parentWorkManager.shareDiff)
+	case "eth_submitHashrate":
+		if len(request.Params) < 1 {
+			w.Write(GetInvalidParamsError(request.ID))
+			return
+		}
+
+		g.setReportedHashrate(workerName, utils.HexStrToBigInt(request.Params[0]))
+
+		w.Write(jsonrpc.MarshalResponse(jsonrpc.Response{
+			JSONRPCVersion: jsonrpc.Version,
+			ID:             request.ID,
+			Result:         true,
+			Error:          nil,
+		}))
+	default:
+		w.Write(jsonrpc.MarshalResponse(jsonrpc.Response{
+			JSONRPCVersion: jsonrpc.Version,
+			ID:             request.ID,
+			Result:         nil,
+			Error:          "Method not found",
+		}))
+	}
+}
@@ -0,0 +1,258 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/big"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// EthereumStratum/1.0.0 miners submit only the nonce, so the mix digest has to be
+// recomputed on the pool side. This is a minimal port of the go-ethereum light
+// (cache-only) hashimoto implementation, which is enough to recover the mix digest
+// for the subsequent share verification.
+
+const (
+	datasetInitBytes   = 1 << 30 // Bytes in dataset at genesis
+	datasetGrowthBytes = 1 << 23 // Dataset growth per epoch
+	cacheInitBytes     = 1 << 24 // Bytes in cache at genesis
+	cacheGrowthBytes   = 1 << 17 // Cache growth per epoch
+	epochLength        = 30000   // Blocks per epoch
+	mixBytes           = 128     // Width of mix
+	hashBytes          = 64      // Hash length in bytes
+	hashWords          = 16      // Number of 32 bit ints in a hash
+	datasetParents     = 256     // Number of parents of each dataset element
+	cacheRounds        = 3       // Number of rounds in cache production
+	loopAccesses       = 64      // Number of accesses in hashimoto loop
+)
+
+// lightCache is the verification cache of a single epoch, generated once
+type lightCache struct {
+	once  sync.Once
+	cache []uint32
+}
+
+func (c *lightCache) generate(epoch uint64) []uint32 {
+	c.once.Do(func() {
+		c.cache = generateCache(calcCacheSize(epoch), seedHash(epoch))
+	})
+	return c.cache
+}
+
+// lightCaches stores the verification caches by epoch (the previous one is kept for the stale shares)
+type lightCaches struct {
+	caches map[uint64]*lightCache
+	mux    sync.Mutex
+}
+
+var ethashLightCaches = lightCaches{caches: make(map[uint64]*lightCache)}
+
+// entry returns the cache entry of the given epoch, and whether it was just created
+func (l *lightCaches) entry(epoch uint64) (*lightCache, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if c, ok := l.caches[epoch]; ok {
+		return c, false
+	}
+
+	c := &lightCache{}
+	l.caches[epoch] = c
+	return c, true
+}
+
+// get returns (and generates if needed) the verification cache for the given epoch.
+// The generation takes a few seconds, so it is done outside of the lock, and the next epoch's cache is prepared in advance.
+func (l *lightCaches) get(epoch uint64) []uint32 {
+	c, created := l.entry(epoch)
+	if created {
+		l.mux.Lock()
+		for cachedEpoch := range l.caches {
+			if cachedEpoch+1 < epoch {
+				delete(l.caches, cachedEpoch)
+			}
+		}
+		l.mux.Unlock()
+	}
+
+	if next, created := l.entry(epoch + 1); created {
+		go next.generate(epoch + 1)
+	}
+
+	return c.generate(epoch)
+}
+
+func keccak(h hash.Hash, dest []byte, data []byte) {
+	h.Reset()
+	h.Write(data)
+	h.Sum(dest[:0])
+}
+
+func isPrime(n uint64) bool {
+	return new(big.Int).SetUint64(n).ProbablyPrime(1)
+}
+
+func calcCacheSize(epoch uint64) uint64 {
+	size := cacheInitBytes + cacheGrowthBytes*epoch - hashBytes
+	for !isPrime(size / hashBytes) {
+		size -= 2 * hashBytes
+	}
+	return size
+}
+
+func calcDatasetSize(epoch uint64) uint64 {
+	size := datasetInitBytes + datasetGrowthBytes*epoch - mixBytes
+	for !isPrime(size / mixBytes) {
+		size -= 2 * mixBytes
+	}
+	return size
+}
+
+func seedHash(epoch uint64) []byte {
+	seed := make([]byte, 32)
+	keccak256 := sha3.NewLegacyKeccak256()
+	for i := uint64(0); i < epoch; i++ {
+		keccak(keccak256, seed, seed)
+	}
+	return seed
+}
+
+func fnv(a, b uint32) uint32 {
+	return a*0x01000193 ^ b
+}
+
+func fnvHash(mix []uint32, data []uint32) {
+	for i := 0; i < len(mix); i++ {
+		mix[i] = mix[i]*0x01000193 ^ data[i]
+	}
+}
+
+func generateCache(size uint64, seed []byte) []uint32 {
+	cache := make([]byte, size)
+	rows := int(size) / hashBytes
+	keccak512 := sha3.NewLegacyKeccak512()
+
+	// Sequentially produce the initial dataset
+	keccak(keccak512, cache, seed)
+	for offset := uint64(hashBytes); offset < size; offset += hashBytes {
+		keccak(keccak512, cache[offset:], cache[offset-hashBytes:offset])
+	}
+
+	// Use a low-round version of randmemohash
+	temp := make([]byte, hashBytes)
+	for i := 0; i < cacheRounds; i++ {
+		for j := 0; j < rows; j++ {
+			srcOff := ((j - 1 + rows) % rows) * hashBytes
+			dstOff := j * hashBytes
+			xorOff := int(binary.LittleEndian.Uint32(cache[dstOff:])%uint32(rows)) * hashBytes
+
+			for k := 0; k < hashBytes; k++ {
+				temp[k] = cache[srcOff+k] ^ cache[xorOff+k]
+			}
+			keccak(keccak512, cache[dstOff:], temp)
+		}
+	}
+
+	out := make([]uint32, size/4)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint32(cache[i*4:])
+	}
+	return out
+}
+
+func generateDatasetItem(cache []uint32, index uint32, keccak512 hash.Hash) []uint32 {
+	rows := uint32(len(cache) / hashWords)
+
+	mix := make([]byte, hashBytes)
+	binary.LittleEndian.PutUint32(mix, cache[(index%rows)*hashWords]^index)
+	for i := 1; i < hashWords; i++ {
+		binary.LittleEndian.PutUint32(mix[i*4:], cache[(index%rows)*hashWords+uint32(i)])
+	}
+	keccak(keccak512, mix, mix)
+
+	intMix := make([]uint32, hashWords)
+	for i := 0; i < len(intMix); i++ {
+		intMix[i] = binary.LittleEndian.Uint32(mix[i*4:])
+	}
+
+	for i := uint32(0); i < datasetParents; i++ {
+		parent := fnv(index^i, intMix[i%16]) % rows
+		fnvHash(intMix, cache[parent*hashWords:])
+	}
+
+	for i, val := range intMix {
+		binary.LittleEndian.PutUint32(mix[i*4:], val)
+	}
+	keccak(keccak512, mix, mix)
+
+	for i := 0; i < len(intMix); i++ {
+		intMix[i] = binary.LittleEndian.Uint32(mix[i*4:])
+	}
+	return intMix
+}
+
+// computeMixDigest calculates the ethash mix digest for the given header hash, nonce and block number
+func computeMixDigest(headerHash []byte, nonce uint64, blockNumber uint64) []byte {
+	epoch := blockNumber / epochLength
+	return hashimotoLight(calcDatasetSize(epoch), ethashLightCaches.get(epoch), headerHash, nonce)
+}
+
+func hashimotoLight(datasetSize uint64, cache []uint32, headerHash []byte, nonce uint64) []byte {
+	rows := uint32(datasetSize / mixBytes)
+
+	keccak512 := sha3.NewLegacyKeccak512()
+
+	// Combine header+nonce into a 64 byte seed
+	seed := make([]byte, 40, hashBytes)
+	copy(seed, headerHash)
+	binary.LittleEndian.PutUint64(seed[32:], nonce)
+	keccak(keccak512, seed[:0], seed)
+	seed = seed[:hashBytes]
+	seedHead := binary.LittleEndian.Uint32(seed)
+
+	// Start the mix with replicated seed
+	mix := make([]uint32, mixBytes/4)
+	for i := 0; i < len(mix); i++ {
+		mix[i] = binary.LittleEndian.Uint32(seed[i%16*4:])
+	}
+
+	// Mix in random dataset nodes
+	temp := make([]uint32, len(mix))
+	for i := 0; i < loopAccesses; i++ {
+		parent := fnv(uint32(i)^seedHead, mix[i%len(mix)]) % rows
+		for j := uint32(0); j < mixBytes/hashBytes; j++ {
+			copy(temp[j*hashWords:], generateDatasetItem(cache, 2*parent+j, keccak512))
+		}
+		fnvHash(mix, temp)
+	}
+
+	// Compress mix
+	for i := 0; i < len(mix); i += 4 {
+		mix[i/4] = fnv(fnv(fnv(mix[i], mix[i+1]), mix[i+2]), mix[i+3])
+	}
+	mix = mix[:len(mix)/4]
+
+	digest := make([]byte, 32)
+	for i, val := range mix {
+		binary.LittleEndian.PutUint32(digest[i*4:], val)
+	}
+	return digest
+}
@@ -0,0 +1,83 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// The vectors are taken from go-ethereum's consensus/ethash tests
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCalcSizes(t *testing.T) {
+	tests := []struct {
+		epoch       uint64
+		cacheSize   uint64
+		datasetSize uint64
+	}{
+		{0, 16776896, 1073739904},
+		{1, 16907456, 1082130304},
+	}
+
+	for _, test := range tests {
+		if size := calcCacheSize(test.epoch); size != test.cacheSize {
+			t.Errorf("epoch %d: expected cache size %d, got %d", test.epoch, test.cacheSize, size)
+		}
+		if size := calcDatasetSize(test.epoch); size != test.datasetSize {
+			t.Errorf("epoch %d: expected dataset size %d, got %d", test.epoch, test.datasetSize, size)
+		}
+	}
+}
+
+func TestSeedHash(t *testing.T) {
+	if seed := seedHash(0); !bytes.Equal(seed, make([]byte, 32)) {
+		t.Errorf("expected zero seed for epoch 0, got %x", seed)
+	}
+
+	want := mustDecodeHex(t, "290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563")
+	if seed := seedHash(1); !bytes.Equal(seed, want) {
+		t.Errorf("expected seed %x for epoch 1, got %x", want, seed)
+	}
+}
+
+func TestHashimotoLight(t *testing.T) {
+	cache := generateCache(1024, make([]byte, 32))
+
+	cachePrefix := make([]byte, 16)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint32(cachePrefix[i*4:], cache[i])
+	}
+	if want := mustDecodeHex(t, "7ce2991c951f7bf4c4c1bb119887ee07"); !bytes.Equal(cachePrefix, want) {
+		t.Fatalf("expected cache to start with %x, got %x", want, cachePrefix)
+	}
+
+	headerHash := mustDecodeHex(t, "c9149cc0386e689d789a1c2f3d5d169a61a6218ed30e74414dc736e442ef3d1f")
+	want := mustDecodeHex(t, "e4073cffaef931d37117cefd9afd27ea0f1cad6a981dd2605c4a1ac97c519800")
+	if digest := hashimotoLight(32*1024, cache, headerHash, 0); !bytes.Equal(digest, want) {
+		t.Fatalf("expected mix digest %x, got %x", want, digest)
+	}
+}
@@ -0,0 +1,72 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import "sync"
+
+// workMailbox is a bounded, latest-job-wins work queue of a single session, so the broadcast never blocks on a slow client
+type workMailbox struct {
+	ch  chan []string
+	mux sync.Mutex
+}
+
+func newWorkMailbox() *workMailbox {
+	return &workMailbox{ch: make(chan []string, 1)}
+}
+
+// deliver puts the work to the mailbox, replacing the undelivered one
+func (m *workMailbox) deliver(work []string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	select {
+	case <-m.ch:
+		// The previous job wasn't sent yet, and is outdated now
+	default:
+	}
+
+	m.ch <- work
+}
+
+// SubscribeNotifications subscribes the new session mailbox to the work notifications
+func (w *WorkManager) SubscribeNotifications() *workMailbox {
+	mailbox := newWorkMailbox()
+
+	w.subscriptionsMux.Lock()
+	w.subscriptions[mailbox] = struct{}{}
+	w.subscriptionsMux.Unlock()
+
+	return mailbox
+}
+
+// UnsubscribeNotifications removes the session mailbox from the work notifications
+func (w *WorkManager) UnsubscribeNotifications(mailbox *workMailbox) {
+	w.subscriptionsMux.Lock()
+	delete(w.subscriptions, mailbox)
+	w.subscriptionsMux.Unlock()
+}
+
+// broadcastWork delivers the work to all subscribed sessions
+func (w *WorkManager) broadcastWork() {
+	w.subscriptionsMux.Lock()
+	defer w.subscriptionsMux.Unlock()
+
+	for mailbox := range w.subscriptions {
+		// Every session gets its own copy, since the share target is applied by the session itself
+		mailbox.deliver(w.GetLastWork(false))
+	}
+}
@@ -0,0 +1,125 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/types"
+	"github.com/sirupsen/logrus"
+)
+
+// negotiatedConn is a net.Conn that replays the data consumed during the protocol negotiation
+type negotiatedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+// Read reads the data from the negotiation buffer first, and then from the connection itself
+func (c *negotiatedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+var httpMethodPrefixes = [][]byte{[]byte("POST"), []byte("GET "), []byte("HEAD"), []byte("PUT ")}
+
+func isHTTPRequest(firstBytes []byte) bool {
+	for _, prefix := range httpMethodPrefixes {
+		if bytes.Equal(firstBytes, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectProtocol detects the stratum dialect by the first JSONRPC method
+func detectProtocol(method string) types.StratumProtocol {
+	if strings.HasPrefix(method, "mining.") {
+		return types.EthereumStratumProtocol
+	}
+
+	// eth_submitLogin, or anything else (which would be rejected by the EthProxy handler as unauthorized)
+	return types.EthProxyProtocol
+}
+
+// NegotiateProtocol reads the first message of the connection, and passes the connection to the matching protocol handler
+func (g *Gateway) NegotiateProtocol(conn net.Conn) {
+	// Add 5 sec timeout
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	reader := bufio.NewReader(conn)
+
+	firstBytes, err := reader.Peek(4)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	if isHTTPRequest(firstBytes) {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix":   "gateway",
+			"ip":       getIPAddress(conn),
+			"protocol": types.StratumProtocolNameMap[types.GetworkProtocol],
+		}).Debug("Negotiated protocol")
+
+		// Timeouts are managed by the getwork HTTP server
+		conn.SetReadDeadline(time.Time{})
+		g.getworkListener.handOver(&negotiatedConn{Conn: conn, reader: reader})
+		return
+	}
+
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		write(conn, GetInvalidRequestError(0))
+		conn.Close()
+		return
+	}
+
+	// ReadSlice's result is only valid until the next read
+	firstLine := make([]byte, len(line))
+	copy(firstLine, line)
+
+	var request struct {
+		Method string `json:"method"`
+	}
+	json.Unmarshal(firstLine, &request)
+
+	negotiated := &negotiatedConn{Conn: conn, reader: io.MultiReader(bytes.NewReader(firstLine), reader)}
+
+	protocol := detectProtocol(request.Method)
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":   "gateway",
+		"ip":       getIPAddress(conn),
+		"protocol": types.StratumProtocolNameMap[protocol],
+	}).Debug("Negotiated protocol")
+
+	g.openConns.setProtocol(conn, protocol)
+
+	switch protocol {
+	case types.EthereumStratumProtocol:
+		g.HandleEthereumStratumConnection(negotiated)
+	default:
+		g.HandleConnection(negotiated)
+	}
+}
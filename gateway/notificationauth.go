@@ -0,0 +1,79 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/flexpool/solo/utils"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	notificationTokenHeader     = "X-Solo-Token"
+	notificationSignatureHeader = "X-Solo-Signature"
+)
+
+// NotificationAuthOptions specifies how the work notifications are authenticated (every check is optional)
+type NotificationAuthOptions struct {
+	Token           string       // Expected in the URL path (e.g. http://127.0.0.1:8000/<token>) or X-Solo-Token header
+	HMACSecret      string       // Hex HMAC-SHA256 of the body is expected in X-Solo-Signature header
+	AllowedNetworks []*net.IPNet // Source IP allow-list
+}
+
+// authenticateNotification checks that the work notification comes from the trusted source
+func (w *WorkManager) authenticateNotification(r *http.Request, body []byte) error {
+	options := w.notificationAuthOptions
+
+	if len(options.AllowedNetworks) != 0 && !utils.IPInNetworks(r.RemoteAddr, options.AllowedNetworks) {
+		return errors.New("source IP is not allowed")
+	}
+
+	if options.Token != "" {
+		token := r.Header.Get(notificationTokenHeader)
+		if token == "" {
+			token = strings.Trim(r.URL.Path, "/")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(options.Token)) != 1 {
+			return errors.New("invalid token")
+		}
+	}
+
+	if options.HMACSecret != "" {
+		signature, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get(notificationSignatureHeader), "0x"))
+		if err != nil || len(signature) == 0 {
+			return errors.New("missing or malformed signature")
+		}
+
+		mac := hmac.New(sha256.New, []byte(options.HMACSecret))
+		mac.Write(body)
+
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+	}
+
+	return nil
+}
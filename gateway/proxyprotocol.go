@@ -0,0 +1,154 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProxyProtocolOptions specifies the PROXY protocol (v1/v2) support of the gateway listeners
+type ProxyProtocolOptions struct {
+	Enabled         bool
+	TrustedNetworks []*net.IPNet // Only these upstreams are expected to send the PROXY header
+}
+
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const proxyProtocolV1MaxLength = 107
+
+// proxiedConn is a net.Conn with the client address taken from the PROXY header
+type proxiedConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+}
+
+// Read reads the data that follows the PROXY header
+func (c *proxiedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the real client address
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// readProxyHeader reads the PROXY protocol header, and returns the client address (nil for LOCAL/UNKNOWN connections)
+func readProxyHeader(reader *bufio.Reader) (net.Addr, error) {
+	firstByte, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch firstByte[0] {
+	case 'P':
+		return readProxyHeaderV1(reader)
+	case proxyProtocolV2Signature[0]:
+		return readProxyHeaderV2(reader)
+	default:
+		return nil, errors.New("missing PROXY header")
+	}
+}
+
+// readProxyHeaderV1 parses the human-readable header (e.g. "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n")
+func readProxyHeaderV1(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < proxyProtocolV1MaxLength {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("invalid PROXY v1 header")
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, errors.New("invalid PROXY v1 header")
+	}
+
+	if fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if (fields[1] != "TCP4" && fields[1] != "TCP6") || len(fields) != 6 {
+		return nil, errors.New("invalid PROXY v1 header")
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, errors.New("invalid PROXY v1 source address")
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyHeaderV2 parses the binary header
+func readProxyHeaderV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(header[:12], proxyProtocolV2Signature) || header[12]>>4 != 2 {
+		return nil, errors.New("invalid PROXY v2 header")
+	}
+
+	addresses := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, addresses); err != nil {
+		return nil, err
+	}
+
+	switch header[12] & 0x0F {
+	case 0x0: // LOCAL command (e.g. the proxy's health check)
+		return nil, nil
+	case 0x1: // PROXY command
+	default:
+		return nil, errors.New("unsupported PROXY v2 command")
+	}
+
+	switch header[13] {
+	case 0x11: // TCP over IPv4
+		if len(addresses) < 12 {
+			return nil, errors.New("invalid PROXY v2 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(addresses[0:4]), Port: int(binary.BigEndian.Uint16(addresses[8:10]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(addresses) < 36 {
+			return nil, errors.New("invalid PROXY v2 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(addresses[0:16]), Port: int(binary.BigEndian.Uint16(addresses[32:34]))}, nil
+	default:
+		// Unsupported address family, the proxy's address is kept
+		return nil, nil
+	}
+}
@@ -0,0 +1,115 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+func proxyHeaderV2(command byte, family byte, addresses []byte) []byte {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+func proxyAddressesV4(srcIP string, srcPort uint16) []byte {
+	addresses := make([]byte, 12)
+	copy(addresses[0:4], net.ParseIP(srcIP).To4())
+	copy(addresses[4:8], net.ParseIP("192.0.2.2").To4())
+	binary.BigEndian.PutUint16(addresses[8:10], srcPort)
+	binary.BigEndian.PutUint16(addresses[10:12], 443)
+	return addresses
+}
+
+func proxyAddressesV6(srcIP string, srcPort uint16) []byte {
+	addresses := make([]byte, 36)
+	copy(addresses[0:16], net.ParseIP(srcIP).To16())
+	copy(addresses[16:32], net.ParseIP("2001:db8::2").To16())
+	binary.BigEndian.PutUint16(addresses[32:34], srcPort)
+	binary.BigEndian.PutUint16(addresses[34:36], 443)
+	return addresses
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	badSignature := proxyHeaderV2(0x1, 0x11, proxyAddressesV4("203.0.113.7", 56324))
+	badSignature[11] = 'X'
+
+	tests := []struct {
+		name     string
+		header   []byte
+		wantAddr string // Empty if no address is expected
+		wantErr  bool
+	}{
+		{"v1 TCP4", []byte("PROXY TCP4 203.0.113.7 192.0.2.2 56324 443\r\n"), "203.0.113.7:56324", false},
+		{"v1 TCP6", []byte("PROXY TCP6 2001:db8::7 2001:db8::2 56324 443\r\n"), "[2001:db8::7]:56324", false},
+		{"v1 UNKNOWN", []byte("PROXY UNKNOWN\r\n"), "", false},
+		{"v1 too long", []byte("PROXY TCP4 " + strings.Repeat("1", proxyProtocolV1MaxLength) + "\r\n"), "", true},
+		{"v1 without CRLF", []byte("PROXY TCP4 203.0.113.7 192.0.2.2 56324 443\n"), "", true},
+		{"v1 invalid address", []byte("PROXY TCP4 203.0.113 192.0.2.2 56324 443\r\n"), "", true},
+		{"v2 IPv4", proxyHeaderV2(0x1, 0x11, proxyAddressesV4("203.0.113.7", 56324)), "203.0.113.7:56324", false},
+		{"v2 IPv6", proxyHeaderV2(0x1, 0x21, proxyAddressesV6("2001:db8::7", 56324)), "[2001:db8::7]:56324", false},
+		{"v2 LOCAL", proxyHeaderV2(0x0, 0x00, nil), "", false},
+		{"v2 unsupported command", proxyHeaderV2(0x2, 0x11, proxyAddressesV4("203.0.113.7", 56324)), "", true},
+		{"v2 short addresses", proxyHeaderV2(0x1, 0x11, proxyAddressesV4("203.0.113.7", 56324)[:8]), "", true},
+		{"v2 truncated addresses", proxyHeaderV2(0x1, 0x11, proxyAddressesV4("203.0.113.7", 56324))[:20], "", true},
+		{"v2 bad signature", badSignature, "", true},
+		{"missing header", []byte(`{"id":1,"method":"eth_submitLogin"}` + "\n"), "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr, err := readProxyHeader(bufio.NewReader(bytes.NewReader(test.header)))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got address %v", addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotAddr := ""
+			if addr != nil {
+				gotAddr = addr.String()
+			}
+			if gotAddr != test.wantAddr {
+				t.Fatalf("expected address %q, got %q", test.wantAddr, gotAddr)
+			}
+		})
+	}
+}
+
+func TestReadProxyHeaderKeepsPayload(t *testing.T) {
+	payload := `{"id":1,"method":"eth_submitLogin"}` + "\n"
+	reader := bufio.NewReader(strings.NewReader("PROXY TCP4 203.0.113.7 192.0.2.2 56324 443\r\n" + payload))
+
+	if _, err := readProxyHeader(reader); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil || line != payload {
+		t.Fatalf("expected payload %q after the header, got %q (%v)", payload, line, err)
+	}
+}
@@ -17,9 +17,11 @@
 package gateway
 
 import (
+	"bufio"
 	"context"
 	"crypto/tls"
 	"net"
+	"net/http"
 	"os"
 	"sync"
 	"time"
@@ -28,6 +30,7 @@ import (
 	"github.com/flexpool/solo/process"
 	"github.com/flexpool/solo/stats"
 	"github.com/flexpool/solo/utils"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -41,11 +44,19 @@ type Gateway struct {
 	cancelContextFunc context.CancelFunc
 	parentWorkManager *WorkManager
 	statsCollector    *stats.Collector
+	banManager        *BanManager
+	connLimiter       *ConnectionLimiter
+	sessionRegistry   *SessionRegistry
+	credentials       *CredentialStore
+	proxyProtocol     ProxyProtocolOptions
 	engineWaitGroup   *sync.WaitGroup
+	getworkListener   *connListener
+	openConns         *openConnections
+	stopped           chan struct{}
 }
 
 // NewGatewayInsecure creates Non SSL gateway instance
-func NewGatewayInsecure(parentWorkManager *WorkManager, bind string, password string, statsCollector *stats.Collector, engineWaitGroup *sync.WaitGroup) (Gateway, error) {
+func NewGatewayInsecure(parentWorkManager *WorkManager, bind string, password string, statsCollector *stats.Collector, banManager *BanManager, connLimiter *ConnectionLimiter, sessionRegistry *SessionRegistry, credentials *CredentialStore, proxyProtocol ProxyProtocolOptions, engineWaitGroup *sync.WaitGroup) (Gateway, error) {
 	err := utils.IsInvalidAddress(bind)
 	if err != nil {
 		return Gateway{}, err
@@ -53,7 +64,24 @@ func NewGatewayInsecure(parentWorkManager *WorkManager, bind string, password st
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
 
-	return Gateway{bind: bind, stratumPassword: password, isSecure: false, context: ctx, cancelContextFunc: cancelFunc, parentWorkManager: parentWorkManager, statsCollector: statsCollector, engineWaitGroup: engineWaitGroup}, nil
+	return Gateway{bind: bind, stratumPassword: password, isSecure: false, context: ctx, cancelContextFunc: cancelFunc, parentWorkManager: parentWorkManager, statsCollector: statsCollector, banManager: banManager, connLimiter: connLimiter, sessionRegistry: sessionRegistry, credentials: credentials, proxyProtocol: proxyProtocol, engineWaitGroup: engineWaitGroup, openConns: newOpenConnections(), stopped: make(chan struct{})}, nil
+}
+
+// NewGatewaySecure creates SSL (TLS) gateway instance
+func NewGatewaySecure(parentWorkManager *WorkManager, bind string, certFile string, keyFile string, password string, statsCollector *stats.Collector, banManager *BanManager, connLimiter *ConnectionLimiter, sessionRegistry *SessionRegistry, credentials *CredentialStore, proxyProtocol ProxyProtocolOptions, engineWaitGroup *sync.WaitGroup) (Gateway, error) {
+	err := utils.IsInvalidAddress(bind)
+	if err != nil {
+		return Gateway{}, err
+	}
+
+	tlsKeyPair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return Gateway{}, errors.Wrap(err, "unable to load TLS key pair")
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	return Gateway{bind: bind, stratumPassword: password, isSecure: true, tlsKeyPair: tlsKeyPair, context: ctx, cancelContextFunc: cancelFunc, parentWorkManager: parentWorkManager, statsCollector: statsCollector, banManager: banManager, connLimiter: connLimiter, sessionRegistry: sessionRegistry, credentials: credentials, proxyProtocol: proxyProtocol, engineWaitGroup: engineWaitGroup, openConns: newOpenConnections(), stopped: make(chan struct{})}, nil
 }
 
 // Run runs the Gateway
@@ -61,6 +89,7 @@ func (g *Gateway) Run() {
 	// Wait group
 	g.engineWaitGroup.Add(1)
 	defer g.engineWaitGroup.Done()
+	defer close(g.stopped)
 
 	laddr, err := net.ResolveTCPAddr("tcp", g.bind)
 	if err != nil {
@@ -86,6 +115,24 @@ func (g *Gateway) Run() {
 		return
 	}
 
+	// HTTP getwork connections are detected by the protocol negotiation, and handed over to the HTTP server
+	g.getworkListener = newConnListener(listener.Addr())
+	getworkServer := &http.Server{
+		Handler:      g,
+		ReadTimeout:  time.Second * 10,
+		WriteTimeout: time.Second * 10,
+		IdleTimeout:  time.Second * 60,
+	}
+	go getworkServer.Serve(g.getworkListener)
+
+	var tlsConfig *tls.Config
+	if g.isSecure {
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{g.tlsKeyPair},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
 	log.Logger.WithFields(logrus.Fields{
 		"prefix": "gateway",
 		"bind":   g.bind,
@@ -97,6 +144,7 @@ func (g *Gateway) Run() {
 		select {
 		case <-g.context.Done():
 			listener.Close()
+			g.shutdown(getworkServer)
 			log.Logger.WithFields(logrus.Fields{
 				"prefix": "gateway",
 				"secure": g.isSecure,
@@ -118,12 +166,84 @@ func (g *Gateway) Run() {
 				}).Error("Unable to accept TCP connection")
 				continue
 			}
-			go g.HandleConnection(conn)
+
+			// Added here, so the shutdown can't start waiting before the handler is counted
+			g.openConns.waitGroup.Add(1)
+			go g.acceptConnection(conn, tlsConfig)
 		}
 	}
 }
 
-// Stop stops the gateway server
+// acceptConnection resolves the client address, applies the bans and limits, and passes the connection to the protocol negotiation
+func (g *Gateway) acceptConnection(conn net.Conn, tlsConfig *tls.Config) {
+	defer g.openConns.waitGroup.Done()
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok && g.connLimiter.limits.KeepAlivePeriod > 0 {
+		tcpConn.SetKeepAlive(true)
+		tcpConn.SetKeepAlivePeriod(g.connLimiter.limits.KeepAlivePeriod)
+	}
+
+	// The PROXY header is sent before anything else (including the TLS handshake)
+	if g.proxyProtocol.Enabled && utils.IPInNetworks(conn.RemoteAddr().String(), g.proxyProtocol.TrustedNetworks) {
+		conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+		reader := bufio.NewReader(conn)
+		clientAddr, err := readProxyHeader(reader)
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"prefix": "gateway",
+				"proxy":  conn.RemoteAddr().String(),
+				"error":  err,
+			}).Warn("Unable to read PROXY header")
+			conn.Close()
+			return
+		}
+
+		if clientAddr == nil {
+			clientAddr = conn.RemoteAddr()
+		}
+
+		conn = &proxiedConn{Conn: conn, reader: reader, remoteAddr: clientAddr}
+	}
+
+	ip := getIPAddress(conn)
+
+	if g.banManager.IsBanned(ip) {
+		conn.Close()
+		return
+	}
+
+	if !g.connLimiter.acquire(ip) {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix":   "gateway",
+			"ip":       ip,
+			"active":   g.connLimiter.ActiveSessions(),
+			"rejected": g.connLimiter.RejectedSessions(),
+		}).Warn("Connection limit exceeded")
+		conn.Close()
+		return
+	}
+
+	conn = &trackedConn{Conn: conn, limiter: g.connLimiter, ip: ip}
+
+	if g.isSecure {
+		// TLS handshake is performed lazily on the first read/write,
+		// so it is covered by the HandleConnection read deadline
+		conn = tls.Server(conn, tlsConfig)
+	}
+
+	g.openConns.add(conn)
+	defer g.openConns.remove(conn)
+
+	g.NegotiateProtocol(conn)
+}
+
+// Stop stops accepting new connections, and starts draining the open ones
 func (g *Gateway) Stop() {
 	g.cancelContextFunc()
 }
+
+// Wait blocks until the gateway is stopped and all of its connections are closed
+func (g *Gateway) Wait() {
+	<-g.stopped
+}
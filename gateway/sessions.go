@@ -0,0 +1,126 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flexpool/solo/types"
+)
+
+// Session is a snapshot of the authenticated stratum session (HTTP getwork is stateless, and has no sessions)
+type Session struct {
+	ID          uint64 `json:"id"`
+	WorkerName  string `json:"workerName"`
+	RemoteAddr  string `json:"remoteAddr"`
+	Protocol    string `json:"protocol"`
+	ConnectedAt int64  `json:"connectedAt"`
+	LastShareAt int64  `json:"lastShareAt"`
+	Difficulty  uint64 `json:"difficulty"`
+	UserAgent   string `json:"userAgent"`
+
+	conn    net.Conn
+	vardiff *vardiff
+}
+
+// SessionRegistry keeps track of the live sessions of all gateways
+type SessionRegistry struct {
+	sessions map[uint64]*Session
+	lastID   uint64
+	mux      sync.Mutex
+}
+
+// NewSessionRegistry creates a new SessionRegistry instance
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{sessions: make(map[uint64]*Session)}
+}
+
+// register adds the newly authenticated session to the registry
+func (r *SessionRegistry) register(conn net.Conn, workerName string, protocol types.StratumProtocol, userAgent string, shareDifficulty *vardiff) *Session {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.lastID++
+	session := &Session{
+		ID:          r.lastID,
+		WorkerName:  workerName,
+		RemoteAddr:  conn.RemoteAddr().String(),
+		Protocol:    types.StratumProtocolNameMap[protocol],
+		ConnectedAt: time.Now().Unix(),
+		UserAgent:   userAgent,
+		conn:        conn,
+		vardiff:     shareDifficulty,
+	}
+	r.sessions[session.ID] = session
+
+	return session
+}
+
+// unregister removes the closed session from the registry
+func (r *SessionRegistry) unregister(session *Session) {
+	if session == nil {
+		return
+	}
+
+	r.mux.Lock()
+	delete(r.sessions, session.ID)
+	r.mux.Unlock()
+}
+
+// recordShare updates the session's last share time
+func (r *SessionRegistry) recordShare(session *Session) {
+	r.mux.Lock()
+	session.LastShareAt = time.Now().Unix()
+	r.mux.Unlock()
+}
+
+// Sessions returns the snapshots of all live sessions, sorted by ID
+func (r *SessionRegistry) Sessions() []Session {
+	r.mux.Lock()
+	sessions := make([]Session, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		sessions = append(sessions, *session)
+	}
+	r.mux.Unlock()
+
+	for i := range sessions {
+		sessions[i].Difficulty = sessions[i].vardiff.Difficulty()
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID < sessions[j].ID
+	})
+
+	return sessions
+}
+
+// Disconnect closes the session's connection. Returns false if there is no such session.
+func (r *SessionRegistry) Disconnect(id uint64) bool {
+	r.mux.Lock()
+	session, ok := r.sessions[id]
+	r.mux.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	session.conn.Close()
+	return true
+}
@@ -0,0 +1,142 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flexpool/solo/jsonrpc"
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/types"
+	"github.com/sirupsen/logrus"
+)
+
+// openConnections tracks the gateway's open stratum connections, so they can be drained on shutdown
+type openConnections struct {
+	conns     map[net.Conn]types.StratumProtocol
+	mux       sync.Mutex
+	waitGroup sync.WaitGroup
+}
+
+func newOpenConnections() *openConnections {
+	return &openConnections{conns: make(map[net.Conn]types.StratumProtocol)}
+}
+
+func (c *openConnections) add(conn net.Conn) {
+	c.mux.Lock()
+	c.conns[conn] = types.EthProxyProtocol
+	c.mux.Unlock()
+}
+
+func (c *openConnections) setProtocol(conn net.Conn, protocol types.StratumProtocol) {
+	c.mux.Lock()
+	if _, ok := c.conns[conn]; ok {
+		c.conns[conn] = protocol
+	}
+	c.mux.Unlock()
+}
+
+func (c *openConnections) remove(conn net.Conn) {
+	c.mux.Lock()
+	delete(c.conns, conn)
+	c.mux.Unlock()
+}
+
+func (c *openConnections) count() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.conns)
+}
+
+// drain sends the final notice to every connection, and expires their read deadlines,
+// so the handlers exit once they finish the request they're currently processing
+func (c *openConnections) drain() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for conn, protocol := range c.conns {
+		// EthProxy has no way to notify the miner, closing the connection is the notice itself
+		if protocol == types.EthereumStratumProtocol {
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			write(conn, marshalEthereumStratumReconnect())
+			conn.SetWriteDeadline(time.Time{})
+		}
+		conn.SetReadDeadline(time.Now())
+	}
+}
+
+func (c *openConnections) closeAll() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for conn := range c.conns {
+		conn.Close()
+	}
+}
+
+func marshalEthereumStratumReconnect() []byte {
+	return jsonrpc.MarshalNotification(jsonrpc.Notification{
+		JSONRPCVersion: jsonrpc.Version,
+		Method:         "client.reconnect",
+		Params:         []interface{}{},
+	})
+}
+
+// shutdown drains the open connections, waiting up to the grace period for the in-flight requests
+func (g *Gateway) shutdown(getworkServer *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.connLimiter.limits.ShutdownGracePeriod)
+	defer cancel()
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":      "gateway",
+		"secure":      g.isSecure,
+		"connections": g.openConns.count(),
+	}).Info("Draining connections")
+
+	getworkDone := make(chan struct{})
+	go func() {
+		getworkServer.Shutdown(ctx)
+		close(getworkDone)
+	}()
+
+	g.openConns.drain()
+
+	handlersDone := make(chan struct{})
+	go func() {
+		g.openConns.waitGroup.Wait()
+		close(handlersDone)
+	}()
+
+	select {
+	case <-handlersDone:
+	case <-ctx.Done():
+		log.Logger.WithFields(logrus.Fields{
+			"prefix":      "gateway",
+			"secure":      g.isSecure,
+			"connections": g.openConns.count(),
+		}).Warn("Shutdown grace period exceeded, closing remaining connections")
+		g.openConns.closeAll()
+		<-handlersDone
+	}
+
+	<-getworkDone
+	getworkServer.Close()
+}
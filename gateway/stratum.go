@@ -23,6 +23,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/flexpool/solo/jsonrpc"
 	"github.com/flexpool/solo/log"
 	"github.com/flexpool/solo/types"
@@ -34,34 +35,142 @@ func write(conn net.Conn, data []byte) (int, error) {
 	return conn.Write(append(data, '\n'))
 }
 
-// RunWorkSender runs a work sender for a given connection
-func (g *Gateway) RunWorkSender(conn net.Conn) {
-	// Creating a channel and subscribing it to the work receiver
-	ch := make(chan []string)
+// workWriteTimeout is the time a client has to receive the job, slower clients are dropped
+const workWriteTimeout = time.Second * 10
 
-	g.parentWorkManager.SubscribeNotifications(ch)
+// RunWorkSender runs a work sender for a given connection until the session is done
+func (g *Gateway) RunWorkSender(conn net.Conn, sessionDone <-chan struct{}, marshalWork func(work []string) []byte) {
+	mailbox := g.parentWorkManager.SubscribeNotifications()
+	defer g.parentWorkManager.UnsubscribeNotifications(mailbox)
 
 	for {
-		work := <-ch
-		_, err := write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
-			JSONRPCVersion: jsonrpc.Version,
-			ID:             0,
-			Result:         work,
-		}))
+		select {
+		case <-sessionDone:
+			return
+		case work := <-mailbox.ch:
+			conn.SetWriteDeadline(time.Now().Add(workWriteTimeout))
+			_, err := write(conn, marshalWork(work))
+			conn.SetWriteDeadline(time.Time{})
+
+			if err != nil {
+				log.Logger.WithFields(logrus.Fields{
+					"prefix": "gateway",
+					"ip":     getIPAddress(conn),
+					"error":  err,
+				}).Warn("Unable to send the job, dropping the client")
 
-		if err != nil {
-			break
+				// Unblocks the session's reader as well
+				conn.Close()
+				return
+			}
 		}
 	}
+}
+
+func marshalEthProxyWork(work []string) []byte {
+	return jsonrpc.MarshalResponse(jsonrpc.Response{
+		JSONRPCVersion: jsonrpc.Version,
+		ID:             0,
+		Result:         work,
+	})
+}
+
+// authenticate checks the worker credentials, and registers the worker in the stats collector
+func (g *Gateway) authenticate(workerName string, password string, ip string, protocol types.StratumProtocol) bool {
+	if !g.checkCredentials(workerName, password, ip) {
+		return false
+	}
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":      "gateway",
+		"worker-name": workerName,
+		"ip":          ip,
+		"protocol":    types.StratumProtocolNameMap[protocol],
+	}).Info("Authenticated new worker")
+
+	g.statsCollector.Mux.Lock()
+	pendingStat := g.statsCollector.PendingStats[workerName]
+	pendingStat.IPAddress = ip
+	g.statsCollector.PendingStats[workerName] = pendingStat
+	g.statsCollector.Mux.Unlock()
+
+	return true
+}
+
+// accountShare writes the submitted share to the worker's pending stat
+func (g *Gateway) accountShare(workerName string, ip string, shareType types.ShareType, shareDifficulty uint64) {
+	g.statsCollector.Mux.Lock()
+	pendingStat := g.statsCollector.PendingStats[workerName]
+	pendingStat.IPAddress = ip
+
+	switch shareType {
+	case types.ShareValid:
+		pendingStat.ValidShares++
+		pendingStat.ValidSharesDifficulty += float64(shareDifficulty)
+		g.statsCollector.Hashrate.RecordShare(workerName, shareDifficulty)
+		if g.statsCollector.Database.IncrValidShares(shareDifficulty) != nil {
+			log.Logger.Error("Unable to increment valid shares counter")
+		}
+	case types.ShareStale:
+		pendingStat.StaleShares++
+	case types.ShareInvalid:
+		pendingStat.InvalidShares++
+	case types.ShareDuplicate:
+		pendingStat.DuplicateShares++
+	}
+
+	g.statsCollector.PendingStats[workerName] = pendingStat
+	g.statsCollector.Mux.Unlock()
+
+	g.banManager.RecordShare(ip, shareType == types.ShareInvalid || shareType == types.ShareDuplicate)
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":     "gateway",
+		"worker":     workerName,
+		"ip":         ip,
+		"share-diff": humanize.SIWithDigits(float64(shareDifficulty), 2, "H"),
+	}).Info("Received " + types.ShareTypeNameMap[shareType] + " share")
+}
+
+// logDifficultyChange logs the retargeted share difficulty
+func logDifficultyChange(workerName string, ip string, difficulty uint64) {
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":     "gateway",
+		"worker":     workerName,
+		"ip":         ip,
+		"share-diff": humanize.SIWithDigits(float64(difficulty), 2, "H"),
+	}).Debug("Retargeted share difficulty")
+}
+
+// setReportedHashrate writes the worker's reported hashrate to its pending stat
+func (g *Gateway) setReportedHashrate(workerName string, reportedHashrate *big.Int) {
+	g.statsCollector.Mux.Lock()
+	pendingStat := g.statsCollector.PendingStats[workerName]
+	pendingStat.ReportedHashrate, _ = big.NewFloat(0).SetInt(reportedHashrate).Float64()
+	g.statsCollector.PendingStats[workerName] = pendingStat
+	g.statsCollector.Mux.Unlock()
+}
 
-	// Closed channel would be automatically unsubscribed by work receiver garbege collector
-	close(ch)
+func getIPAddress(conn net.Conn) string {
+	return getIPAddressFromString(conn.RemoteAddr().String())
 }
 
-// HandleConnection handles the gateway connection
+func getIPAddressFromString(addr string) string {
+	ipSplitted := strings.Split(addr, ":")
+	return strings.Join(ipSplitted[:len(ipSplitted)-1], ":")
+}
+
+// HandleConnection handles the gateway connection speaking EthProxy protocol
 func (g *Gateway) HandleConnection(conn net.Conn) {
 	defer conn.Close()
 
+	// Stops the work sender
+	sessionDone := make(chan struct{})
+	defer close(sessionDone)
+
+	var session *Session
+	defer func() { g.sessionRegistry.unregister(session) }()
+
 	// Add 5 sec timeout
 	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
 
@@ -71,10 +180,16 @@ func (g *Gateway) HandleConnection(conn net.Conn) {
 
 	var workerName string
 
-	ipSplitted := strings.Split(conn.RemoteAddr().String(), ":")
-	ip := strings.Join(ipSplitted[:len(ipSplitted)-1], ":")
+	ip := getIPAddress(conn)
+
+	shareDifficulty := g.parentWorkManager.newVardiff()
 
 	for scanner.Scan() {
+		// The IP might have been banned by this, or by any other connection
+		if g.banManager.IsBanned(ip) {
+			return
+		}
+
 		request, err := jsonrpc.UnmarshalRequest(scanner.Bytes())
 		if err != nil {
 			write(conn, GetInvalidRequestError(0))
@@ -82,6 +197,7 @@ func (g *Gateway) HandleConnection(conn net.Conn) {
 				"prefix": "gateway",
 				"ip":     ip,
 			}).Warn("Invalid JSONRPC request")
+			g.banManager.RecordMalformedRequest(ip)
 
 			// Close connection if not authenticated
 			if !authenticated {
@@ -103,22 +219,11 @@ func (g *Gateway) HandleConnection(conn net.Conn) {
 
 			workerName = request.Params[0]
 
-			if request.Params[1] != g.stratumPassword {
-				log.Logger.WithFields(logrus.Fields{
-					"prefix":      "gateway",
-					"worker-name": workerName,
-					"ip":          ip,
-				}).Warn("Invalid password")
+			if !g.authenticate(workerName, request.Params[1], ip, types.EthProxyProtocol) {
 				write(conn, GetInvalidCredentialsError(request.ID))
 				return
 			}
 
-			log.Logger.WithFields(logrus.Fields{
-				"prefix":      "gateway",
-				"worker-name": workerName,
-				"ip":          ip,
-			}).Info("Authenticated new worker")
-
 			write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
 				JSONRPCVersion: jsonrpc.Version,
 				ID:             request.ID,
@@ -127,34 +232,45 @@ func (g *Gateway) HandleConnection(conn net.Conn) {
 			}))
 
 			authenticated = true
+			session = g.sessionRegistry.register(conn, workerName, types.EthProxyProtocol, "", shareDifficulty)
 
-			// Remove timeout
-			conn.SetReadDeadline(time.Time{})
-
-			g.statsCollector.Mux.Lock()
-			pendingStat := g.statsCollector.PendingStats[workerName]
-			pendingStat.IPAddress = ip
-			g.statsCollector.Mux.Unlock()
+			// Replacing the login timeout with the idle one
+			g.refreshIdleDeadline(conn)
 
 			// Starting work sender
-			go g.RunWorkSender(conn)
+			go g.RunWorkSender(conn, sessionDone, func(work []string) []byte {
+				if shareDifficulty.retarget() {
+					logDifficultyChange(workerName, ip, shareDifficulty.Difficulty())
+				}
+				return marshalEthProxyWork(shareDifficulty.applyToWork(work))
+			})
 
 			continue
 		}
 
 		switch request.Method {
 		case "eth_getWork":
+			g.refreshIdleDeadline(conn)
+
+			if !g.parentWorkManager.Node.Healthy() {
+				write(conn, GetNodeUnhealthyError(request.ID))
+				continue
+			}
+
 			write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
 				JSONRPCVersion: jsonrpc.Version,
 				ID:             request.ID,
-				Result:         g.parentWorkManager.GetLastWork(true),
+				Result:         shareDifficulty.applyToWork(g.parentWorkManager.GetLastWork(false)),
 				Error:          nil,
 			}))
 		case "eth_submitWork":
+			g.refreshIdleDeadline(conn)
+
 			if len(request.Params) < 3 || len(request.Params[0]) != 18 || len(request.Params[1]) != 66 || len(request.Params[2]) != 66 {
 				write(conn, GetInvalidParamsError(request.ID))
 			} else {
-				shareType, err := g.submitShare(request.Params, workerName)
+				jobDifficulty := shareDifficulty.jobDifficulty(request.Params[1])
+				shareType, err := g.submitShare(request.Params, workerName, jobDifficulty)
 				if err != nil {
 					write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
 						JSONRPCVersion: jsonrpc.Version,
@@ -165,55 +281,37 @@ func (g *Gateway) HandleConnection(conn net.Conn) {
 					continue
 				}
 
-				if shareType == 0 || shareType == 1 {
+				if shareType == types.ShareValid || shareType == types.ShareStale {
 					write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
 						JSONRPCVersion: jsonrpc.Version,
 						ID:             request.ID,
 						Result:         true,
 						Error:          nil,
 					}))
+				} else if shareType == types.ShareDuplicate {
+					write(conn, GetDuplicateShareError(request.ID))
 				} else {
 					write(conn, GetInvalidShareError(request.ID))
 				}
 
-				g.statsCollector.Mux.Lock()
-				pendingStat := g.statsCollector.PendingStats[workerName]
-				pendingStat.IPAddress = ip
-
-				switch shareType {
-				case types.ShareValid:
-					pendingStat.ValidShares++
-					if g.statsCollector.Database.IncrValidShares() != nil {
-						log.Logger.Error("Unable to increment valid shares counter")
-					}
-				case types.ShareStale:
-					pendingStat.StaleShares++
-				case types.ShareInvalid:
-					pendingStat.InvalidShares++
-				}
-
-				g.statsCollector.PendingStats[workerName] = pendingStat
-				g.statsCollector.Mux.Unlock()
+				g.accountShare(workerName, ip, shareType, jobDifficulty)
+				g.sessionRegistry.recordShare(session)
 
-				log.Logger.WithFields(logrus.Fields{
-					"prefix": "gateway",
-					"worker": workerName,
-					"ip":     ip,
-				}).Info("Received " + types.ShareTypeNameMap[shareType] + " share")
+				if (shareType == types.ShareValid || shareType == types.ShareStale) && shareDifficulty.registerShare() {
+					// Sending the current job with the new share target
+					logDifficultyChange(workerName, ip, shareDifficulty.Difficulty())
+					write(conn, marshalEthProxyWork(shareDifficulty.applyToWork(g.parentWorkManager.GetLastWork(false))))
+				}
 			}
 		case "eth_submitHashrate":
+			g.refreshIdleDeadline(conn)
+
 			if len(request.Params) < 1 {
 				write(conn, GetInvalidParamsError(request.ID))
 				continue
 			}
 
-			reportedHashrateBigInt := utils.HexStrToBigInt(request.Params[0])
-
-			g.statsCollector.Mux.Lock()
-			pendingStat := g.statsCollector.PendingStats[workerName]
-			pendingStat.ReportedHashrate, _ = big.NewFloat(0).SetInt(reportedHashrateBigInt).Float64()
-			g.statsCollector.PendingStats[workerName] = pendingStat
-			g.statsCollector.Mux.Unlock()
+			g.setReportedHashrate(workerName, utils.HexStrToBigInt(request.Params[0]))
 
 			write(conn, jsonrpc.MarshalResponse(jsonrpc.Response{
 				JSONRPCVersion: jsonrpc.Version,
@@ -0,0 +1,161 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"encoding/hex"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/flexpool/solo/utils"
+)
+
+const maxRetargetFactor = 4   // Difficulty can't be changed more than 4x per retarget
+const retargetThreshold = 0.1 // Difficulty is not changed if the difference is less than 10%
+const maxRememberedJobs = 8   // Same as the work history length
+
+// VardiffOptions specifies the variable difficulty configuration
+type VardiffOptions struct {
+	Enabled               bool
+	TargetSharesPerMinute float64
+	MinDifficulty         uint64
+	MaxDifficulty         uint64
+	RetargetInterval      time.Duration
+}
+
+// vardiff holds the per-connection share difficulty state
+type vardiff struct {
+	options             VardiffOptions
+	difficulty          uint64
+	lastRetarget        time.Time
+	sharesSinceRetarget uint64
+
+	// Difficulty sent with the job is used to validate the shares of that job
+	jobDifficulties map[string]uint64 // map[<header-hash>]<share-difficulty>
+	jobOrder        []string
+
+	mux sync.Mutex
+}
+
+func newVardiff(options VardiffOptions, initialDifficulty uint64) *vardiff {
+	if options.Enabled {
+		if initialDifficulty < options.MinDifficulty {
+			initialDifficulty = options.MinDifficulty
+		} else if initialDifficulty > options.MaxDifficulty {
+			initialDifficulty = options.MaxDifficulty
+		}
+	}
+
+	return &vardiff{
+		options:         options,
+		difficulty:      initialDifficulty,
+		lastRetarget:    time.Now(),
+		jobDifficulties: make(map[string]uint64),
+	}
+}
+
+func difficultyToTarget(difficulty uint64) *big.Int {
+	return big.NewInt(0).Div(utils.BigMax256bit, big.NewInt(0).SetUint64(difficulty))
+}
+
+func difficultyToTargetHex(difficulty uint64) string {
+	return "0x" + hex.EncodeToString(utils.PadByteArrayStart(difficultyToTarget(difficulty).Bytes(), 32))
+}
+
+// Difficulty returns the current share difficulty
+func (v *vardiff) Difficulty() uint64 {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	return v.difficulty
+}
+
+// applyToWork returns the copy of the work with the current share target, and remembers the difficulty sent with the job
+func (v *vardiff) applyToWork(work []string) []string {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	out := make([]string, len(work))
+	copy(out, work)
+	out[2] = difficultyToTargetHex(v.difficulty)
+
+	headerHash := work[0]
+	if prevDifficulty, ok := v.jobDifficulties[headerHash]; ok {
+		// The job was resent with the new difficulty, the miner still might submit shares found with the previous one
+		if v.difficulty < prevDifficulty {
+			v.jobDifficulties[headerHash] = v.difficulty
+		}
+		return out
+	}
+
+	v.jobDifficulties[headerHash] = v.difficulty
+	v.jobOrder = append(v.jobOrder, headerHash)
+	if len(v.jobOrder) > maxRememberedJobs {
+		delete(v.jobDifficulties, v.jobOrder[0])
+		v.jobOrder = v.jobOrder[1:]
+	}
+
+	return out
+}
+
+// jobDifficulty returns the share difficulty which was sent with the job
+func (v *vardiff) jobDifficulty(headerHash string) uint64 {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	if difficulty, ok := v.jobDifficulties[headerHash]; ok {
+		return difficulty
+	}
+	return v.difficulty
+}
+
+// registerShare registers the submitted share, and retargets the difficulty if it's time to
+func (v *vardiff) registerShare() bool {
+	v.mux.Lock()
+	v.sharesSinceRetarget++
+	v.mux.Unlock()
+	return v.retarget()
+}
+
+// retarget adjusts the difficulty towards the target shares per minute rate, and returns true if it was changed
+func (v *vardiff) retarget() bool {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	if !v.options.Enabled {
+		return false
+	}
+
+	elapsed := time.Since(v.lastRetarget)
+	if elapsed < v.options.RetargetInterval {
+		return false
+	}
+
+	sharesPerMinute := float64(v.sharesSinceRetarget) / elapsed.Minutes()
+	v.lastRetarget = time.Now()
+	v.sharesSinceRetarget = 0
+
+	factor := math.Max(math.Min(sharesPerMinute/v.options.TargetSharesPerMinute, maxRetargetFactor), 1.0/maxRetargetFactor)
+	newDifficulty := math.Max(math.Min(float64(v.difficulty)*factor, float64(v.options.MaxDifficulty)), float64(v.options.MinDifficulty))
+
+	if math.Abs(newDifficulty-float64(v.difficulty))/float64(v.difficulty) < retargetThreshold {
+		return false
+	}
+
+	v.difficulty = uint64(newDifficulty)
+	return true
+}
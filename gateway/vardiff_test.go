@@ -0,0 +1,108 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+var testVardiffOptions = VardiffOptions{
+	Enabled:               true,
+	TargetSharesPerMinute: 6,
+	MinDifficulty:         500,
+	MaxDifficulty:         2000,
+	RetargetInterval:      time.Minute,
+}
+
+func TestNewVardiffClampsInitialDifficulty(t *testing.T) {
+	tests := []struct {
+		initial uint64
+		want    uint64
+	}{
+		{100, 500},
+		{1000, 1000},
+		{5000, 2000},
+	}
+
+	for _, test := range tests {
+		if difficulty := newVardiff(testVardiffOptions, test.initial).Difficulty(); difficulty != test.want {
+			t.Errorf("initial %d: expected difficulty %d, got %d", test.initial, test.want, difficulty)
+		}
+	}
+}
+
+func TestVardiffRetarget(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     VardiffOptions
+		initial     uint64
+		shares      uint64
+		elapsed     time.Duration
+		wantChanged bool
+		wantDiff    uint64
+	}{
+		{"raised at most 4x", VardiffOptions{Enabled: true, TargetSharesPerMinute: 6, MinDifficulty: 1, MaxDifficulty: 1e9, RetargetInterval: time.Minute}, 1000, 600, time.Minute, true, 4000},
+		{"lowered at most 4x", VardiffOptions{Enabled: true, TargetSharesPerMinute: 6, MinDifficulty: 1, MaxDifficulty: 1e9, RetargetInterval: time.Minute}, 1000, 0, time.Minute, true, 250},
+		{"clamped to the max difficulty", testVardiffOptions, 1000, 24, time.Minute, true, 2000},
+		{"clamped to the min difficulty", testVardiffOptions, 1000, 0, time.Minute, true, 500},
+		{"kept within the threshold", testVardiffOptions, 1000, 6, time.Minute, false, 1000},
+		{"kept before the interval", testVardiffOptions, 1000, 600, time.Second, false, 1000},
+		{"kept when disabled", VardiffOptions{}, 1000, 600, time.Minute, false, 1000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := newVardiff(test.options, test.initial)
+			v.lastRetarget = time.Now().Add(-test.elapsed)
+			v.sharesSinceRetarget = test.shares
+
+			if changed := v.retarget(); changed != test.wantChanged {
+				t.Fatalf("expected changed %v, got %v", test.wantChanged, changed)
+			}
+			if difficulty := v.Difficulty(); difficulty != test.wantDiff {
+				t.Fatalf("expected difficulty %d, got %d", test.wantDiff, difficulty)
+			}
+		})
+	}
+}
+
+func TestVardiffJobDifficulty(t *testing.T) {
+	v := newVardiff(testVardiffOptions, 1000)
+	work := []string{"0x01", "0x02", "0x03", "0x04"}
+
+	v.applyToWork(work)
+	if difficulty := v.jobDifficulty("0x01"); difficulty != 1000 {
+		t.Fatalf("expected job difficulty 1000, got %d", difficulty)
+	}
+
+	// The job resent with a lower difficulty accepts the shares of both
+	v.difficulty = 500
+	v.applyToWork(work)
+	if difficulty := v.jobDifficulty("0x01"); difficulty != 500 {
+		t.Fatalf("expected job difficulty 500, got %d", difficulty)
+	}
+
+	// The older jobs are forgotten, and validated with the current difficulty
+	for i := 0; i < maxRememberedJobs; i++ {
+		v.applyToWork([]string{string(rune('a' + i)), "0x02", "0x03", "0x04"})
+	}
+	v.difficulty = 2000
+	if difficulty := v.jobDifficulty("0x01"); difficulty != 2000 {
+		t.Fatalf("expected forgotten job difficulty 2000, got %d", difficulty)
+	}
+}
@@ -59,6 +59,17 @@ func (b Block) NumberU64() uint64 { return b.number }
 var hasher = ethash.New()
 
 func (g *Gateway) submitBlock(submittedWork []string, blockNumber uint64, workerName string, actualTarget *big.Int) {
+	defer g.engineWaitGroup.Done()
+
+	// The round ends with the submission, the shares accounted while the block is harvested belong to the next one
+	roundBoundary, err := g.statsCollector.Database.MarkRoundBoundary()
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "gateway",
+			"error":  err,
+		}).Error("Unable to mark the round boundary")
+	}
+
 	// Submitting the work first
 	status, err := g.parentWorkManager.Node.SubmitWork(submittedWork)
 
@@ -107,17 +118,25 @@ func (g *Gateway) submitBlock(submittedWork []string, blockNumber uint64, worker
 		"hash":         harvestedBlock.Hash,
 	}).Info("⚡️ Submitted block found in blockchain")
 
-	sharesMined, err := g.statsCollector.Database.GetValidSharesThenReset()
+	// The round is closed once the block is found in the blockchain
+	g.parentWorkManager.bestShares.newRound(roundBoundary.Timestamp)
+	roundTime, hashesMined, err := g.statsCollector.Database.CloseRound(roundBoundary)
 	if err != nil {
 		log.Logger.WithFields(logrus.Fields{
 			"prefix": "gateway",
-			"error":  err.Error(),
-		}).Warn("Unable to get valid share counter")
+			"error":  err,
+		}).Error("Unable to close the round")
 	}
 
-	roundTime := g.statsCollector.Database.GetRoundTime()
 	difficulty := float64(utils.MustSoftHexToUint64(harvestedBlock.Difficulty))
-	hashesMined := float64(sharesMined) * float64(g.parentWorkManager.shareDiff)
+
+	// Every share is weighted by its own difficulty. The counter is empty if it was lost
+	// (e.g. the database was restored), luck is unknown then
+	var luck *float64
+	if hashesMined > 0 {
+		roundLuck := difficulty / hashesMined
+		luck = &roundLuck
+	}
 
 	// Writing found block to DB
 	g.statsCollector.Database.WriteMinedBlock(db.Block{
@@ -130,11 +149,11 @@ func (g *Gateway) submitBlock(submittedWork []string, blockNumber uint64, worker
 		Confirmed:   false,
 		MinedHashes: hashesMined,
 		RoundTime:   roundTime,
-		Luck:        difficulty / hashesMined,
+		Luck:        luck,
 	})
 }
 
-func (g *Gateway) validateShare(submittedWork []string, workerName string) (types.ShareType, error) {
+func (g *Gateway) validateShare(submittedWork []string, workerName string, shareDifficulty uint64) (types.ShareType, error) {
 	// workerName is required to know who mined the block, if there share mines it
 
 	g.parentWorkManager.workHistory.Mux.Lock()
@@ -148,12 +167,12 @@ func (g *Gateway) validateShare(submittedWork []string, workerName string) (type
 	var isStale bool
 
 	blockNumber := utils.MustSoftHexToUint64(fullWork[3])
-	if fullWork[3] != g.parentWorkManager.lastWork[3] {
+	if fullWork[3] != g.parentWorkManager.GetLastWork(false)[3] {
 		isStale = true
 	}
 
 	share := Block{
-		target:      g.parentWorkManager.shareTargetBigInt,
+		target:      difficultyToTarget(shareDifficulty),
 		hashNoNonce: common.HexToHash(fullWork[0]),
 		nonce:       utils.MustSoftHexToUint64(submittedWork[0]),
 		mixDigest:   common.HexToHash(submittedWork[2]),
@@ -163,27 +182,35 @@ func (g *Gateway) validateShare(submittedWork []string, workerName string) (type
 	shareIsValid, actualTarget := hasher.Verify(share)
 
 	if shareIsValid {
+		// Checking after the verification, so the invalid shares can't occupy the nonces
+		if !g.parentWorkManager.workHistory.MarkSubmitted(fullWork[0], share.nonce) {
+			return types.ShareDuplicate, nil
+		}
+
 		if utils.HexStrToBigInt(fullWork[2]).Cmp(actualTarget) > 0 {
+			// The engine waits for the pending block submissions before closing the database
+			g.engineWaitGroup.Add(1)
 			go g.submitBlock(submittedWork, blockNumber, workerName, actualTarget)
-			g.parentWorkManager.BestShareTarget = utils.BigMax256bit
-		} else {
-			if g.parentWorkManager.BestShareTarget.Cmp(actualTarget) == 1 {
-				float64ActualDifficulty, _ := big.NewFloat(0).SetInt(big.NewInt(0).Div(utils.BigMax256bit, actualTarget)).Float64()
+		} else if roundBest, workerBest, roundStart := g.parentWorkManager.bestShares.record(workerName, actualTarget); workerBest {
+			// Every worker's improvement is written, so the best shares can be listed per worker
+			float64ActualDifficulty, _ := big.NewFloat(0).SetInt(big.NewInt(0).Div(utils.BigMax256bit, actualTarget)).Float64()
+			if roundBest {
 				log.Logger.WithFields(logrus.Fields{
 					"prefix":      "gateway",
+					"worker":      workerName,
 					"actual-diff": humanize.SIWithDigits(float64ActualDifficulty, 2, "H"),
 				}).Info("New best share")
-				err := g.statsCollector.Database.WriteBestShare(db.BestShare{
-					WorkerName:            workerName,
-					ActualShareDifficulty: float64ActualDifficulty,
-					Timestamp:             time.Now().Unix(),
-				}, time.Now().Unix())
-				if err != nil {
-					log.Logger.WithFields(logrus.Fields{
-						"prefix": "gateway",
-					}).Error("Unable to write best share to DB")
-				}
-				g.parentWorkManager.BestShareTarget = actualTarget
+			}
+			err := g.statsCollector.Database.WriteBestShare(db.BestShare{
+				WorkerName:            workerName,
+				ActualShareDifficulty: float64ActualDifficulty,
+				Timestamp:             time.Now().Unix(),
+				RoundStart:            roundStart,
+			}, time.Now().Unix())
+			if err != nil {
+				log.Logger.WithFields(logrus.Fields{
+					"prefix": "gateway",
+				}).Error("Unable to write best share to DB")
 			}
 		}
 
@@ -196,6 +223,6 @@ func (g *Gateway) validateShare(submittedWork []string, workerName string) (type
 	return types.ShareInvalid, nil
 }
 
-func (g *Gateway) submitShare(work []string, workerName string) (types.ShareType, error) {
-	return g.validateShare(work, workerName)
+func (g *Gateway) submitShare(work []string, workerName string, shareDifficulty uint64) (types.ShareType, error) {
+	return g.validateShare(work, workerName, shareDifficulty)
 }
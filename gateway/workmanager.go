@@ -25,6 +25,8 @@ import (
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/flexpool/solo/log"
@@ -41,11 +43,22 @@ type OrderedWorkMap struct {
 	Map   map[string][]string
 	Order []string
 	Mux   sync.Mutex
+
+	// Job IDs are used by the EthereumStratum protocol to refer to the work
+	jobIDs       map[string]string // map[<header-hash>]<job-id>
+	headerHashes map[string]string // map[<job-id>]<header-hash>
+	lastJobID    uint64
+
+	// Accepted nonces are remembered to detect the duplicate shares
+	submittedNonces map[string]map[uint64]struct{} // map[<header-hash>]set[<nonce>]
 }
 
 // Init initializes the OrderedWorkMap
 func (o *OrderedWorkMap) Init() {
 	o.Map = make(map[string][]string)
+	o.jobIDs = make(map[string]string)
+	o.headerHashes = make(map[string]string)
+	o.submittedNonces = make(map[string]map[uint64]struct{})
 }
 
 // Append appends new work to the OrderedWorkMap
@@ -53,6 +66,12 @@ func (o *OrderedWorkMap) Append(headerHash string, work []string) {
 	o.Mux.Lock()
 	o.Map[headerHash] = work
 	o.Order = append(o.Order, headerHash)
+
+	o.lastJobID++
+	jobID := fmt.Sprintf("%08x", o.lastJobID)
+	o.jobIDs[headerHash] = jobID
+	o.headerHashes[jobID] = headerHash
+	o.submittedNonces[headerHash] = make(map[uint64]struct{})
 	o.Mux.Unlock()
 }
 
@@ -61,6 +80,9 @@ func (o *OrderedWorkMap) Shift() {
 	o.Mux.Lock()
 	headerHash := o.Order[0]
 	delete(o.Map, headerHash)
+	delete(o.headerHashes, o.jobIDs[headerHash])
+	delete(o.jobIDs, headerHash)
+	delete(o.submittedNonces, headerHash)
 	o.Order = o.Order[1:]
 	o.Mux.Unlock()
 }
@@ -73,26 +95,87 @@ func (o *OrderedWorkMap) Len() int {
 	return out
 }
 
+// Contains returns true if the work with the given header hash is in the OrderedWorkMap
+func (o *OrderedWorkMap) Contains(headerHash string) bool {
+	o.Mux.Lock()
+	_, ok := o.Map[headerHash]
+	o.Mux.Unlock()
+	return ok
+}
+
+// MarkSubmitted remembers the submitted nonce of the work, and returns false if it was already submitted (or the work is unknown)
+func (o *OrderedWorkMap) MarkSubmitted(headerHash string, nonce uint64) bool {
+	o.Mux.Lock()
+	defer o.Mux.Unlock()
+	nonces, ok := o.submittedNonces[headerHash]
+	if !ok {
+		return false
+	}
+	if _, ok := nonces[nonce]; ok {
+		return false
+	}
+	nonces[nonce] = struct{}{}
+	return true
+}
+
+// GetJobID returns the job ID of the work by its header hash
+func (o *OrderedWorkMap) GetJobID(headerHash string) (string, bool) {
+	o.Mux.Lock()
+	jobID, ok := o.jobIDs[headerHash]
+	o.Mux.Unlock()
+	return jobID, ok
+}
+
+// GetByJobID returns the work by its job ID
+func (o *OrderedWorkMap) GetByJobID(jobID string) ([]string, bool) {
+	o.Mux.Lock()
+	defer o.Mux.Unlock()
+	headerHash, ok := o.headerHashes[jobID]
+	if !ok {
+		return nil, false
+	}
+	work, ok := o.Map[headerHash]
+	return work, ok
+}
+
 // WorkManager is a struct for the work manager daemon
 type WorkManager struct {
 	httpServer        *http.Server
 	shuttingDown      bool
-	subscriptions     []chan []string
+	subscriptions     map[*workMailbox]struct{}
 	subscriptionsMux  sync.Mutex
 	lastWork          []string
+	lastWorkMux       sync.RWMutex // Not newWorkMux, since the last work is read while the new work is broadcast
 	workHistory       OrderedWorkMap
 	shareDiff         uint64
 	shareTargetHex    string
 	shareTargetBigInt *big.Int
 	shareDiffBigInt   *big.Int
-	BestShareTarget   *big.Int
-	Node              *nodeapi.Node
+	bestShares        *roundBestShares
+	Node              *nodeapi.NodePool
 	engineWaitGroup   *sync.WaitGroup
+	extraNonces       *extraNonceAllocator
+	vardiffOptions    VardiffOptions
+
+	bind                    string
+	notificationAuthOptions NotificationAuthOptions
+	rejectedNotifications   uint64
+	pollInterval            time.Duration
+	watchdogTimeout         time.Duration
+	newWorkMux              sync.Mutex
+	lastNewWorkTime         time.Time
+	context                 context.Context
+	cancelContextFunc       context.CancelFunc
 }
 
 // GetLastWork returns last work
 func (w *WorkManager) GetLastWork(applyShareDiff bool) []string {
-	work := w.lastWork
+	// Copying, since the last work is shared with the work history
+	w.lastWorkMux.RLock()
+	work := make([]string, len(w.lastWork))
+	copy(work, w.lastWork)
+	w.lastWorkMux.RUnlock()
+
 	// Apply Share Diff
 	if applyShareDiff {
 		work[2] = w.shareTargetHex
@@ -102,17 +185,28 @@ func (w *WorkManager) GetLastWork(applyShareDiff bool) []string {
 }
 
 // NewWorkManager creates new WorkManager instance
-func NewWorkManager(bind string, shareDiff uint64, node *nodeapi.Node, engineWaitGroup *sync.WaitGroup) *WorkManager {
+func NewWorkManager(bind string, notificationAuthOptions NotificationAuthOptions, shareDiff uint64, vardiffOptions VardiffOptions, pollInterval time.Duration, watchdogTimeout time.Duration, node *nodeapi.NodePool, engineWaitGroup *sync.WaitGroup) *WorkManager {
+	ctx, cancelFunc := context.WithCancel(context.Background())
 	shareTargetBigInt := big.NewInt(0).Div(big.NewInt(0).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0)), big.NewInt(0).SetUint64(shareDiff))
 	workManager := WorkManager{
-		shareDiff:         shareDiff,
-		shareDiffBigInt:   big.NewInt(0).SetUint64(shareDiff),
-		shareTargetBigInt: shareTargetBigInt,
-		shareTargetHex:    "0x" + hex.EncodeToString(utils.PadByteArrayStart(shareTargetBigInt.Bytes(), 32)),
-		lastWork:          []string{"0x0", "0x0", "0x0", "0x0"},
-		BestShareTarget:   big.NewInt(0).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0)),
-		Node:              node,
-		engineWaitGroup:   engineWaitGroup,
+		shareDiff:               shareDiff,
+		shareDiffBigInt:         big.NewInt(0).SetUint64(shareDiff),
+		shareTargetBigInt:       shareTargetBigInt,
+		shareTargetHex:          "0x" + hex.EncodeToString(utils.PadByteArrayStart(shareTargetBigInt.Bytes(), 32)),
+		lastWork:                []string{"0x0", "0x0", "0x0", "0x0"},
+		subscriptions:           make(map[*workMailbox]struct{}),
+		vardiffOptions:          vardiffOptions,
+		bestShares:              newRoundBestShares(time.Now().Unix()),
+		extraNonces:             newExtraNonceAllocator(),
+		Node:                    node,
+		engineWaitGroup:         engineWaitGroup,
+		bind:                    bind,
+		notificationAuthOptions: notificationAuthOptions,
+		pollInterval:            pollInterval,
+		watchdogTimeout:         watchdogTimeout,
+		lastNewWorkTime:         time.Now(),
+		context:                 ctx,
+		cancelContextFunc:       cancelFunc,
 	}
 
 	mux := http.NewServeMux()
@@ -130,30 +224,47 @@ func NewWorkManager(bind string, shareDiff uint64, node *nodeapi.Node, engineWai
 
 		if err != nil {
 			log.Logger.WithFields(logrus.Fields{
-				"prefix":    "workmanager",
-				"error":     err,
-				"node-type": types.NodeStringMap[workManager.Node.Type],
+				"prefix": "workmanager",
+				"error":  err,
 			}).Error("Unable to read work notification")
 			return
 		}
 
+		if err := workManager.authenticateNotification(r, data); err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"prefix":         "workmanager",
+				"error":          err,
+				"remote-addr":    r.RemoteAddr,
+				"user-agent":     r.UserAgent(),
+				"rejected-total": atomic.AddUint64(&workManager.rejectedNotifications, 1),
+			}).Warn("Rejected work notification")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
 		var workNotification []string
 		var workNotificationParseError error
 
-		switch workManager.Node.Type {
-		case types.GethNode:
-			workNotification, workNotificationParseError = parseGethWorkNotification(data)
-		case types.OpenEthereumNode:
-			workNotification, workNotificationParseError = parseOpenEthereumWorkNotification(data)
-		default:
-			panic("unknown node type " + strconv.Itoa(types.OpenEthereumNode))
+		// Notifications may be sent by any of the nodes, so all of their formats are tried
+		for _, nodeType := range workManager.Node.Types() {
+			switch nodeType {
+			case types.GethNode:
+				workNotification, workNotificationParseError = parseGethWorkNotification(data)
+			case types.OpenEthereumNode:
+				workNotification, workNotificationParseError = parseOpenEthereumWorkNotification(data)
+			default:
+				panic("unknown node type " + strconv.Itoa(int(nodeType)))
+			}
+
+			if workNotificationParseError == nil {
+				break
+			}
 		}
 
 		if workNotificationParseError != nil {
 			log.Logger.WithFields(logrus.Fields{
-				"prefix":    "workmanager",
-				"error":     workNotificationParseError,
-				"node-type": types.NodeStringMap[workManager.Node.Type],
+				"prefix": "workmanager",
+				"error":  workNotificationParseError,
 			}).Error("Unable to parse work notification")
 			return
 		}
@@ -164,70 +275,99 @@ func NewWorkManager(bind string, shareDiff uint64, node *nodeapi.Node, engineWai
 			"user-agent":   r.UserAgent(),
 		}).Debug("Received a new work notification")
 
-		if len(workNotification) != 4 {
-			log.Logger.WithFields(logrus.Fields{
-				"prefix":    "workmanager",
-				"error":     workNotificationParseError,
-				"node-type": types.NodeStringMap[workManager.Node.Type],
-			}).Error("Invalid work notification (" + fmt.Sprintf("%v", workNotification) + ")")
-			return
-		}
+		workManager.processNewWork(workNotification, "notification")
+	})
 
-		var channelIndexesToClean []int
+	workManager.httpServer = &http.Server{
+		Addr:    bind,
+		Handler: mux,
+	}
 
-		workManager.lastWork = workNotification
+	workManager.workHistory.Init()
 
-		workWithShareDifficulty := make([]string, 4)
-		copy(workWithShareDifficulty, workNotification)
-		workWithShareDifficulty[2] = workManager.shareTargetHex
+	return &workManager
+}
 
-		// Sending work notification to all subscribers
-		workManager.subscriptionsMux.Lock()
-		for i, ch := range workManager.subscriptions {
-			if !isChanClosed(ch) {
-				ch <- workWithShareDifficulty
-			} else {
-				channelIndexesToClean = append(channelIndexesToClean, i)
-			}
-		}
+// processNewWork adds the new work to the history, and broadcasts it to all subscribers
+func (w *WorkManager) processNewWork(work []string, source string) {
+	if len(work) != 4 {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix":    "workmanager",
+			"source":    source,
+			"node-type": types.NodeStringMap[w.Node.Type()],
+		}).Error("Invalid work notification (" + fmt.Sprintf("%v", work) + ")")
+		return
+	}
 
-		length := len(workManager.subscriptions)
+	if !w.Node.Healthy() {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "workmanager",
+			"source": source,
+		}).Debug("Node is unhealthy, ignoring the new work")
+		return
+	}
 
-		for _, chIndex := range channelIndexesToClean {
-			workManager.subscriptions[chIndex] = workManager.subscriptions[length-1]
-			workManager.subscriptions = workManager.subscriptions[:length-1]
-		}
-		workManager.subscriptionsMux.Unlock()
-		workManager.workHistory.Append(workNotification[0], workNotification)
+	// Work may be received from multiple sources at once
+	w.newWorkMux.Lock()
+	defer w.newWorkMux.Unlock()
 
-		if workManager.workHistory.Len() > 8 {
-			// Removing unneeded (9th in history) work
-			workManager.workHistory.Shift()
-		}
+	if w.workHistory.Contains(work[0]) {
+		// Already known work
+		return
+	}
 
-		workTarget, _ := big.NewInt(0).SetString(utils.Clear0x(workNotification[2]), 16)
-		workDifficulty, _ := big.NewFloat(0).SetInt(big.NewInt(0).Div(utils.BigMax256bit, workTarget)).Float64()
+	w.lastNewWorkTime = time.Now()
 
-		log.Logger.WithFields(logrus.Fields{
-			"prefix":      "workmanager",
-			"header-hash": workNotification[0][2:10],
-			"block-diff":  humanize.SIWithDigits(workDifficulty, 2, "H"),
-		}).Info("New job for #" + strconv.FormatUint(utils.MustSoftHexToUint64(workNotification[3]), 10))
-	})
+	// Adding the work to history before broadcasting, so it can be resolved (e.g. by job ID) by the subscribers
+	w.workHistory.Append(work[0], work)
 
-	workManager.httpServer = &http.Server{
-		Addr:    bind,
-		Handler: mux,
+	if w.workHistory.Len() > 8 {
+		// Removing unneeded (9th in history) work
+		w.workHistory.Shift()
 	}
 
-	workManager.workHistory.Init()
+	w.lastWorkMux.Lock()
+	w.lastWork = work
+	w.lastWorkMux.Unlock()
 
-	return &workManager
+	w.broadcastWork()
+
+	workTarget, _ := big.NewInt(0).SetString(utils.Clear0x(work[2]), 16)
+	workDifficulty, _ := big.NewFloat(0).SetInt(big.NewInt(0).Div(utils.BigMax256bit, workTarget)).Float64()
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":      "workmanager",
+		"header-hash": work[0][2:10],
+		"block-diff":  humanize.SIWithDigits(workDifficulty, 2, "H"),
+		"source":      source,
+	}).Info("New job for #" + strconv.FormatUint(utils.MustSoftHexToUint64(work[3]), 10))
 }
 
 // Run function runs the WorkManager
 func (w *WorkManager) Run() {
 	w.engineWaitGroup.Add(1)
+
+	if w.pollInterval > 0 {
+		go w.RunWorkPoller()
+	}
+
+	if w.Node.HasWebSocket() {
+		go w.RunWorkSubscriber()
+	}
+
+	if w.watchdogTimeout > 0 {
+		go w.RunWatchdog()
+	}
+
+	go w.RunHealthMonitor()
+
+	if w.bind == "" {
+		// Notifications are disabled, work is received by polling or WebSocket subscription only
+		<-w.context.Done()
+		w.engineWaitGroup.Done()
+		return
+	}
+
 	err := w.httpServer.ListenAndServe()
 
 	if !w.shuttingDown {
@@ -243,23 +383,46 @@ func (w *WorkManager) Run() {
 // Stop function stops the WorkManager
 func (w *WorkManager) Stop() {
 	w.shuttingDown = true
+	w.cancelContextFunc()
+	if w.bind == "" {
+		return
+	}
 	err := w.httpServer.Shutdown(context.Background())
 	if err != nil {
 		panic(err)
 	}
 }
 
-// SubscribeNotifications subscribes the given channel to the work receiver
-func (w *WorkManager) SubscribeNotifications(ch chan []string) {
-	w.subscriptions = append(w.subscriptions, ch)
+// RejectedNotifications returns the number of the work notifications rejected by the notification authentication
+func (w *WorkManager) RejectedNotifications() uint64 {
+	return atomic.LoadUint64(&w.rejectedNotifications)
 }
 
-func isChanClosed(ch <-chan []string) bool {
-	select {
-	case <-ch:
-		return true
-	default:
+// NextExtraNonce returns a free extranonce (nonce prefix) for the EthereumStratum session, or false if all of them are taken.
+// The extranonce must be released with ReleaseExtraNonce once the session is closed.
+func (w *WorkManager) NextExtraNonce() (string, bool) {
+	extraNonce, ok := w.extraNonces.allocate()
+	if !ok {
+		return "", false
 	}
+	return fmt.Sprintf("%0"+strconv.Itoa(extraNonceSize*2)+"x", extraNonce), true
+}
+
+// ReleaseExtraNonce frees the extranonce of the closed EthereumStratum session
+func (w *WorkManager) ReleaseExtraNonce(extraNonce string) {
+	value, err := strconv.ParseUint(extraNonce, 16, extraNonceSize*8)
+	if err != nil {
+		return
+	}
+	w.extraNonces.release(uint16(value))
+}
+
+// VardiffEnabled returns true if variable share difficulty is enabled
+func (w *WorkManager) VardiffEnabled() bool {
+	return w.vardiffOptions.Enabled
+}
 
-	return false
+// newVardiff creates the share difficulty state for a new connection
+func (w *WorkManager) newVardiff() *vardiff {
+	return newVardiff(w.vardiffOptions, w.shareDiff)
 }
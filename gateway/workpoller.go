@@ -0,0 +1,224 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// RunWorkPoller polls the node for the new work (used when the node can't push work notifications)
+func (w *WorkManager) RunWorkPoller() {
+	w.engineWaitGroup.Add(1)
+	defer w.engineWaitGroup.Done()
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":   "workmanager",
+		"interval": w.pollInterval,
+	}).Info("Started work poller")
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.context.Done():
+			return
+		case <-ticker.C:
+			work, err := w.Node.GetWork()
+			if err != nil {
+				log.Logger.WithFields(logrus.Fields{
+					"prefix": "workmanager",
+					"error":  err,
+				}).Error("Unable to poll work")
+				continue
+			}
+
+			w.processNewWork(work, "poll")
+		}
+	}
+}
+
+const maxWebSocketReconnectBackoff = time.Second * 30
+
+// fetchWorkForHead requests the work for the new head (the node might need some time to prepare it)
+func (w *WorkManager) fetchWorkForHead(blockNumber uint64) {
+	for i := 0; i < 10; i++ {
+		work, err := w.Node.GetWork()
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"prefix":       "workmanager",
+				"block-number": blockNumber,
+				"error":        err,
+			}).Error("Unable to get work for the new head")
+			return
+		}
+
+		// Nodes that don't report a valid block number can't be checked, so their work is taken as is
+		if len(work) < 4 {
+			w.processNewWork(work, "websocket")
+			return
+		}
+		workBlockNumber, err := strconv.ParseUint(utils.Clear0x(work[3]), 16, 64)
+		if err != nil || workBlockNumber > blockNumber {
+			w.processNewWork(work, "websocket")
+			return
+		}
+
+		select {
+		case <-w.context.Done():
+			return
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":       "workmanager",
+		"block-number": blockNumber,
+	}).Warn("Gave up waiting for the node to prepare the work for the new head")
+}
+
+// RunWorkSubscriber receives the new heads over the node's WebSocket subscription, and requests the new work for them
+func (w *WorkManager) RunWorkSubscriber() {
+	w.engineWaitGroup.Add(1)
+	defer w.engineWaitGroup.Done()
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix": "workmanager",
+	}).Info("Started WebSocket work subscriber")
+
+	backoff := time.Second
+
+	for {
+		err := w.Node.SubscribeNewHeads(w.context, func(blockNumber uint64) {
+			// Subscription is alive, so the next reconnect should be quick
+			backoff = time.Second
+			w.fetchWorkForHead(blockNumber)
+		})
+
+		if w.context.Err() != nil {
+			return
+		}
+
+		log.Logger.WithFields(logrus.Fields{
+			"prefix":  "workmanager",
+			"error":   err,
+			"backoff": backoff,
+		}).Error("WebSocket work subscription failed, reconnecting")
+
+		select {
+		case <-w.context.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxWebSocketReconnectBackoff {
+			backoff = maxWebSocketReconnectBackoff
+		}
+	}
+}
+
+// RunHealthMonitor pauses and resumes the work distribution when the node's health changes
+func (w *WorkManager) RunHealthMonitor() {
+	w.engineWaitGroup.Add(1)
+	defer w.engineWaitGroup.Done()
+
+	for {
+		select {
+		case <-w.context.Done():
+			return
+		case <-w.Node.HealthChanged():
+		}
+
+		if !w.Node.Healthy() {
+			log.Logger.WithFields(logrus.Fields{
+				"prefix": "workmanager",
+			}).Error("Node is syncing or unhealthy, work distribution is paused")
+			continue
+		}
+
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "workmanager",
+		}).Info("Node is healthy, work distribution is resumed")
+
+		// The work that arrived while the node was unhealthy has been ignored
+		work, err := w.Node.GetWork()
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"prefix": "workmanager",
+				"error":  err,
+			}).Error("Unable to get work")
+			continue
+		}
+
+		w.processNewWork(work, "recovery")
+	}
+}
+
+// RunWatchdog warns if no new work was received for too long
+func (w *WorkManager) RunWatchdog() {
+	w.engineWaitGroup.Add(1)
+	defer w.engineWaitGroup.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var lastWarningTime time.Time
+	var stalled bool
+
+	for {
+		select {
+		case <-w.context.Done():
+			return
+		case <-ticker.C:
+			w.newWorkMux.Lock()
+			sinceLastNewWork := time.Since(w.lastNewWorkTime)
+			w.newWorkMux.Unlock()
+
+			if sinceLastNewWork < w.watchdogTimeout {
+				if stalled {
+					log.Logger.WithFields(logrus.Fields{
+						"prefix": "workmanager",
+					}).Info("Receiving new work again")
+					stalled = false
+				}
+				continue
+			}
+
+			// Repeat the warning every watchdog timeout while no work arrives
+			if stalled && time.Since(lastWarningTime) < w.watchdogTimeout {
+				continue
+			}
+
+			stalled = true
+			lastWarningTime = time.Now()
+
+			log.Logger.WithFields(logrus.Fields{
+				"prefix":        "workmanager",
+				"last-new-work": sinceLastNewWork.Round(time.Second),
+				"notifications": w.bind != "",
+				"polling":       w.pollInterval > 0,
+				"websocket":     w.Node.HasWebSocket(),
+			}).Error("⚠️ No new work received for too long! Miners are working on the stale job. Check that the node is synced, and started with --miner.notify (or enable work polling)")
+		}
+	}
+}
@@ -16,7 +16,7 @@
 
 module github.com/flexpool/solo
 
-go 1.14
+go 1.16
 
 require (
 	github.com/btcsuite/btcd v0.20.1-beta // indirect
@@ -31,6 +31,7 @@ require (
 	github.com/syndtr/goleveldb v1.0.1-0.20190923125748-758128399b1d
 	github.com/vmihailenco/msgpack/v5 v5.0.0-beta.1
 	github.com/x-cray/logrus-prefixed-formatter v0.5.2
-	golang.org/x/crypto v0.0.0-20200709230013-948cd5f35899 // indirect
+	golang.org/x/crypto v0.0.0-20200709230013-948cd5f35899
+	golang.org/x/net v0.0.0-20200625001655-4c5254603344
 	golang.org/x/sys v0.0.0-20200625212154-ddb9806d33ae // indirect
 )
@@ -37,6 +37,15 @@ type Request struct {
 	Params         interface{} `json:"params"`
 }
 
+// Notification specifies the JSONRPC server notification. Unlike the requests, its ID is null,
+// so the clients don't take it for a response.
+type Notification struct {
+	JSONRPCVersion string      `json:"jsonrpc"`
+	ID             *int        `json:"id"` // Always nil
+	Method         string      `json:"method"`
+	Params         interface{} `json:"params"`
+}
+
 // Response specifies the JSONRPC gateway response
 type Response struct {
 	JSONRPCVersion string      `json:"jsonrpc"`
@@ -76,3 +85,9 @@ func MarshalRequest(r Request) []byte {
 	req, _ := json.Marshal(r)
 	return req
 }
+
+// MarshalNotification creates a JSONRPC notification bytes from a Notification struct
+func MarshalNotification(n Notification) []byte {
+	notification, _ := json.Marshal(n)
+	return notification
+}
@@ -20,10 +20,14 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"time"
 
 	"github.com/flexpool/solo/configuration"
+	"github.com/flexpool/solo/db"
 	"github.com/flexpool/solo/engine"
+	"github.com/flexpool/solo/gateway"
 	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/nodeapi"
 	"github.com/flexpool/solo/process"
 	"github.com/flexpool/solo/utils"
 	"github.com/sirupsen/logrus"
@@ -62,23 +66,197 @@ func main() {
 	log.SetLogLevel(config.LogLevel)
 
 	// Check the config
-	err = utils.IsInvalidAddress(config.WorkmanagerNotificationsBindAddr)
+	if config.WorkmanagerNotificationsBindAddr == "" && config.WorkmanagerPollIntervalMs == 0 && len(config.NodeWSRPC) == 0 {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Error("At least one work source (Work Receiver bind address, work poll interval or node WebSocket RPC) should be specified")
+		os.Exit(1)
+	}
+
+	if config.WorkmanagerNotificationsBindAddr != "" {
+		err = utils.IsInvalidAddress(config.WorkmanagerNotificationsBindAddr)
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"prefix": "config",
+				"error":  err,
+			}).Error("Invalid Work Receiver bind address")
+			os.Exit(1)
+		}
+	}
+
+	if len(config.NodeWSRPC) != 0 && len(config.NodeWSRPC) != len(config.NodeHTTPRPC) {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Error("Node WebSocket RPC endpoints should be specified for every node (in the same order as HTTP ones)")
+		os.Exit(1)
+	}
+
+	if config.NodeHealthCheckIntervalSecs == 0 {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Error("Node health check interval should be positive")
+		os.Exit(1)
+	}
+
+	notificationsAllowedNetworks, err := utils.ParseIPNetworks(config.WorkmanagerNotificationsAllowedIPs)
 	if err != nil {
 		log.Logger.WithFields(logrus.Fields{
 			"prefix": "config",
 			"error":  err,
-		}).Error("Invalid Work Receiver bind address")
+		}).Error("Invalid Work Receiver allowed IPs")
 		os.Exit(1)
 	}
 
-	if config.GatewayInsecureBindAddr == "" /* && config.GatewaySSLBindAddr == "" */ {
+	if config.GatewayInsecureBindAddr == "" && config.GatewaySecureBindAddr == "" {
 		log.Logger.WithFields(logrus.Fields{
 			"prefix": "config",
 		}).Error("At least one gateway bind address should be specified")
 		os.Exit(1)
 	}
 
-	miningEngine, err := engine.NewMiningEngine(config.WorkmanagerNotificationsBindAddr, config.ShareDifficulty, config.GatewayInsecureBindAddr, "", config.GatewayPassword, config.NodeHTTPRPC, config.DBPath, config.BlockConfirmationsRequired, config.WebServerBind)
+	if config.GatewaySecureBindAddr != "" && (config.GatewayTLSCertFile == "" || config.GatewayTLSKeyFile == "") {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Error("Secure gateway requires both TLS certificate and key files")
+		os.Exit(1)
+	}
+
+	if config.GatewayPassword == "" {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Warn("Shared gateway password is not set, only the workers from the credentials store are able to log in")
+	}
+
+	proxyProtocolTrustedNetworks, err := utils.ParseIPNetworks(config.GatewayProxyProtocolTrustedIPs)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+			"error":  err,
+		}).Error("Invalid PROXY protocol trusted IPs")
+		os.Exit(1)
+	}
+
+	if config.GatewayProxyProtocol && len(proxyProtocolTrustedNetworks) == 0 {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Error("PROXY protocol requires the trusted upstream IPs to be specified")
+		os.Exit(1)
+	}
+
+	banExemptNetworks, err := utils.ParseIPNetworks(config.BanExemptIPs)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+			"error":  err,
+		}).Error("Invalid ban exempt IPs")
+		os.Exit(1)
+	}
+
+	if config.BanEnabled && (config.BanMaxFailedLogins == 0 || config.BanMaxMalformedRequests == 0 || config.BanDurationSecs == 0 || config.BanDurationSecs > config.BanMaxDurationSecs) {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Error("Invalid ban configuration")
+		os.Exit(1)
+	}
+
+	if config.VardiffEnabled && (config.VardiffMinDifficulty == 0 || config.VardiffMinDifficulty > config.VardiffMaxDifficulty || config.VardiffTargetSharesPerMinute <= 0 || config.VardiffRetargetIntervalSecs < 10) {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Error("Invalid vardiff configuration")
+		os.Exit(1)
+	}
+
+	if config.StatsCollectionPeriodSecs <= 0 || config.StatsRetentionSecs < config.StatsCollectionPeriodSecs {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Error("Invalid stats configuration (retention must be at least one collection period)")
+		os.Exit(1)
+	}
+
+	statsOptions := db.StatsOptions{
+		CollectionPeriodSecs: config.StatsCollectionPeriodSecs,
+		RetentionSecs:        config.StatsRetentionSecs,
+	}
+
+	vardiffOptions := gateway.VardiffOptions{
+		Enabled:               config.VardiffEnabled,
+		TargetSharesPerMinute: config.VardiffTargetSharesPerMinute,
+		MinDifficulty:         config.VardiffMinDifficulty,
+		MaxDifficulty:         config.VardiffMaxDifficulty,
+		RetargetInterval:      time.Duration(config.VardiffRetargetIntervalSecs) * time.Second,
+	}
+
+	connectionLimits := gateway.ConnectionLimits{
+		MaxConnections:      config.GatewayMaxConnections,
+		MaxConnectionsPerIP: config.GatewayMaxConnectionsPerIP,
+		IdleTimeout:         time.Duration(config.GatewayIdleTimeoutSecs) * time.Second,
+		KeepAlivePeriod:     time.Duration(config.GatewayKeepAliveSecs) * time.Second,
+		ShutdownGracePeriod: time.Duration(config.GatewayShutdownGraceSecs) * time.Second,
+	}
+
+	proxyProtocolOptions := gateway.ProxyProtocolOptions{
+		Enabled:         config.GatewayProxyProtocol,
+		TrustedNetworks: proxyProtocolTrustedNetworks,
+	}
+
+	banOptions := gateway.BanOptions{
+		Enabled:              config.BanEnabled,
+		MaxFailedLogins:      config.BanMaxFailedLogins,
+		MaxMalformedRequests: config.BanMaxMalformedRequests,
+		MaxInvalidShareRatio: config.BanMaxInvalidShareRatio,
+		MinSharesForRatio:    config.BanMinSharesForRatio,
+		Window:               time.Duration(config.BanWindowSecs) * time.Second,
+		Duration:             time.Duration(config.BanDurationSecs) * time.Second,
+		MaxDuration:          time.Duration(config.BanMaxDurationSecs) * time.Second,
+		ExemptNetworks:       banExemptNetworks,
+	}
+
+	notificationAuthOptions := gateway.NotificationAuthOptions{
+		Token:           config.WorkmanagerNotificationsToken,
+		HMACSecret:      config.WorkmanagerNotificationsHMACSecret,
+		AllowedNetworks: notificationsAllowedNetworks,
+	}
+
+	if config.WorkmanagerNotificationsBindAddr != "" && notificationAuthOptions.Token == "" && notificationAuthOptions.HMACSecret == "" && len(notificationAuthOptions.AllowedNetworks) == 0 {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "config",
+		}).Warn("Work notifications are not authenticated, make sure the Work Receiver bind address is not publicly reachable")
+	}
+
+	nodeHealthCheckOptions := nodeapi.HealthCheckOptions{
+		Interval:    time.Duration(config.NodeHealthCheckIntervalSecs) * time.Second,
+		MinPeers:    config.NodeMinPeers,
+		MaxBlockLag: config.NodeMaxBlockLag,
+	}
+
+	workPollInterval := time.Duration(config.WorkmanagerPollIntervalMs) * time.Millisecond
+	workWatchdogTimeout := time.Duration(config.WorkmanagerWatchdogBlockTimes*config.BlockTimeSecs) * time.Second
+
+	miningEngine, err := engine.NewMiningEngine(engine.Options{
+		WorkmanagerNotificationsBind: config.WorkmanagerNotificationsBindAddr,
+		NotificationAuth:             notificationAuthOptions,
+		WorkPollInterval:             workPollInterval,
+		WorkWatchdogTimeout:          workWatchdogTimeout,
+		ShareDifficulty:              config.ShareDifficulty,
+		Vardiff:                      vardiffOptions,
+		GatewayInsecureBind:          config.GatewayInsecureBindAddr,
+		GatewaySecureBind:            config.GatewaySecureBindAddr,
+		GatewayTLSCertFile:           config.GatewayTLSCertFile,
+		GatewayTLSKeyFile:            config.GatewayTLSKeyFile,
+		GatewayPassword:              config.GatewayPassword,
+		Ban:                          banOptions,
+		ConnectionLimits:             connectionLimits,
+		ProxyProtocol:                proxyProtocolOptions,
+		NodeHTTPRPCs:                 config.NodeHTTPRPC,
+		NodeWSRPCs:                   config.NodeWSRPC,
+		NodeHealthCheck:              nodeHealthCheckOptions,
+		DatabasePath:                 config.DBPath,
+		Stats:                        statsOptions,
+		BlockConfirmationsRequired:   config.BlockConfirmationsRequired,
+		WebServerBind:                config.WebServerBind,
+		WebServerAdminToken:          config.WebServerAdminToken,
+		WebServerCORSAllowedOrigins:  config.WebServerCORSAllowedOrigins,
+	})
 	if err != nil {
 		log.Logger.WithFields(logrus.Fields{
 			"prefix": "engine",
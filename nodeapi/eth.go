@@ -18,10 +18,12 @@ package nodeapi
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
 
 	"github.com/flexpool/solo/utils"
 	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 )
 
 // SubmitWork delegates to `eth_submitWork` API method, and submits work
@@ -34,6 +36,29 @@ func (n *Node) SubmitWork(work []string) (bool, error) {
 	return data.(bool), nil
 }
 
+// GetWork delegates to `eth_getWork` API method, and returns the current work
+func (n *Node) GetWork() ([]string, error) {
+	data, err := n.makeHTTPRPCRequest("eth_getWork", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dataSlice, ok := data.([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected eth_getWork response")
+	}
+
+	work := make([]string, len(dataSlice))
+	for i, item := range dataSlice {
+		work[i], ok = item.(string)
+		if !ok {
+			return nil, errors.New("unexpected eth_getWork response")
+		}
+	}
+
+	return work, nil
+}
+
 // BlockNumber delegates to `eth_blockNumber` API method, and returns the current block number
 func (n *Node) BlockNumber() (uint64, error) {
 	data, err := n.makeHTTPRPCRequest("eth_blockNumber", nil)
@@ -49,6 +74,98 @@ func (n *Node) BlockNumber() (uint64, error) {
 	return blockNumber, nil
 }
 
+// Syncing delegates to `eth_syncing` API method, and returns the node's sync status
+func (n *Node) Syncing() (SyncStatus, error) {
+	data, err := n.makeHTTPRPCRequest("eth_syncing", nil)
+	if err != nil {
+		return SyncStatus{}, err
+	}
+
+	// The node returns the sync progress object while syncing, and false otherwise
+	if syncing, ok := data.(bool); ok {
+		return SyncStatus{Status: syncing}, nil
+	}
+
+	var progress struct {
+		CurrentBlock string `mapstructure:"currentBlock"`
+		HighestBlock string `mapstructure:"highestBlock"`
+	}
+	if err := mapstructure.Decode(data, &progress); err != nil {
+		return SyncStatus{}, errors.Wrap(err, "unexpected eth_syncing response")
+	}
+
+	return SyncStatus{
+		Status:       true,
+		CurrentBlock: utils.MustSoftHexToUint64(progress.CurrentBlock),
+		TargetBlock:  utils.MustSoftHexToUint64(progress.HighestBlock),
+	}, nil
+}
+
+// PeerCount delegates to `net_peerCount` API method, and returns the number of the connected peers
+func (n *Node) PeerCount() (uint64, error) {
+	data, err := n.makeHTTPRPCRequest("net_peerCount", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	peerCountHex, ok := data.(string)
+	if !ok {
+		return 0, errors.New("unexpected net_peerCount response")
+	}
+
+	return strconv.ParseUint(utils.Clear0x(peerCountHex), 16, 64)
+}
+
+// Coinbase delegates to `eth_coinbase` API method, and returns the node's coinbase address
+func (n *Node) Coinbase() (string, error) {
+	data, err := n.makeHTTPRPCRequest("eth_coinbase", nil)
+	if err != nil {
+		return "", err
+	}
+
+	coinbase, ok := data.(string)
+	if !ok {
+		return "", errors.New("unexpected eth_coinbase response")
+	}
+
+	return coinbase, nil
+}
+
+// GetBalance delegates to `eth_getBalance` API method, and returns the address' latest balance (in wei)
+func (n *Node) GetBalance(address string) (*big.Int, error) {
+	data, err := n.makeHTTPRPCRequest("eth_getBalance", []interface{}{address, "latest"})
+	if err != nil {
+		return nil, err
+	}
+
+	balanceHex, ok := data.(string)
+	if !ok {
+		return nil, errors.New("unexpected eth_getBalance response")
+	}
+
+	balance, ok := big.NewInt(0).SetString(utils.Clear0x(balanceHex), 16)
+	if !ok {
+		return nil, errors.New("unexpected eth_getBalance response")
+	}
+
+	return balance, nil
+}
+
+// ChainID delegates to `eth_chainId` API method, and returns the node's chain ID
+func (n *Node) ChainID() (uint64, error) {
+	data, err := n.makeHTTPRPCRequest("eth_chainId", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	chainIDHex, ok := data.(string)
+	if !ok {
+		return 0, errors.New("unexpected eth_chainId response")
+	}
+
+	return strconv.ParseUint(utils.Clear0x(chainIDHex), 16, 64)
+}
+
 // ClientVersion delegates to `eth_blockNumber` API method, and returns the current block number
 func (n *Node) ClientVersion() (string, error) {
 	data, err := n.makeHTTPRPCRequest("web3_clientVersion", nil)
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/flexpool/solo/jsonrpc"
@@ -37,7 +38,11 @@ import (
 // Node is the base OpenEthereum API struct
 type Node struct {
 	httpRPCEndpoint string
-	Type            types.NodeType
+	wsRPCEndpoint   string
+	name            string
+	typeMux         sync.RWMutex // The type is detected by the health checker while the node is in use
+	detected        bool
+	nodeType        types.NodeType
 }
 
 // Block is a block body representation
@@ -50,44 +55,111 @@ type Block struct {
 	Transactions []string `json:"transactions"`
 }
 
-// NewNode creates a new Node instance
-func NewNode(httpRPCEndpoint string) (*Node, error) {
-	if _, err := url.Parse(httpRPCEndpoint); err != nil {
+// httpClient is used for all node requests, so an unresponsive node can't block the caller forever
+var httpClient = &http.Client{Timeout: time.Second * 10}
+
+// SyncStatus is the node's synchronization status
+type SyncStatus struct {
+	Status       bool   `json:"status"`
+	CurrentBlock uint64 `json:"currentBlock"`
+	TargetBlock  uint64 `json:"targetBlock"`
+}
+
+// NewNode creates a new Node instance (WebSocket RPC endpoint is optional)
+func NewNode(httpRPCEndpoint string, wsRPCEndpoint string) (*Node, error) {
+	node, err := newNode(httpRPCEndpoint, wsRPCEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeType, err := node.detectType()
+	if err != nil {
+		return nil, err
+	}
+	node.setType(nodeType)
+
+	return node, nil
+}
+
+// Type returns the detected node type
+func (n *Node) Type() types.NodeType {
+	n.typeMux.RLock()
+	defer n.typeMux.RUnlock()
+	return n.nodeType
+}
+
+// detectedType returns the node type, and false if it isn't detected yet
+func (n *Node) detectedType() (types.NodeType, bool) {
+	n.typeMux.RLock()
+	defer n.typeMux.RUnlock()
+	return n.nodeType, n.detected
+}
+
+// setType sets the detected node type
+func (n *Node) setType(nodeType types.NodeType) {
+	n.typeMux.Lock()
+	defer n.typeMux.Unlock()
+	n.nodeType = nodeType
+	n.detected = true
+}
+
+// newNode creates a new Node instance without contacting the node
+func newNode(httpRPCEndpoint string, wsRPCEndpoint string) (*Node, error) {
+	parsedURL, err := url.Parse(httpRPCEndpoint)
+	if err != nil {
 		return nil, errors.New("invalid HTTP URL")
 	}
 
-	node := Node{httpRPCEndpoint: httpRPCEndpoint}
+	if wsRPCEndpoint != "" {
+		if _, err := url.Parse(wsRPCEndpoint); err != nil {
+			return nil, errors.New("invalid WebSocket URL")
+		}
+	}
+
+	return &Node{httpRPCEndpoint: httpRPCEndpoint, wsRPCEndpoint: wsRPCEndpoint, name: parsedURL.Host}, nil
+}
 
-	// Detecting node type
-	clientVersion, err := node.ClientVersion()
+// detectType detects the node type by its client version
+func (n *Node) detectType() (types.NodeType, error) {
+	clientVersion, err := n.ClientVersion()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed detecting node type")
+		return 0, errors.Wrap(err, "failed detecting node type")
 	}
 
+	nodeType := types.NodeType(types.GethNode)
+
 	clientVersionSplitted := strings.Split(clientVersion, "/")
 	if len(clientVersionSplitted) < 1 {
 		log.Logger.WithFields(logrus.Fields{
 			"prefix": "node",
+			"node":   n.name,
 		}).Warn("Unable to detect node type: received invalid client version. Falling back to Geth.")
 	} else {
 		clientVersion = clientVersionSplitted[0]
 		switch strings.ToLower(clientVersion) {
 		case "geth":
-			node.Type = types.GethNode
+			nodeType = types.GethNode
 		case "openethereum":
-			node.Type = types.OpenEthereumNode
+			nodeType = types.OpenEthereumNode
 		default:
 			log.Logger.WithFields(logrus.Fields{
 				"prefix": "node",
+				"node":   n.name,
 			}).Warn("Unknown node \"" + clientVersion + "\". Falling back to Geth.")
 		}
 	}
 
 	log.Logger.WithFields(logrus.Fields{
 		"prefix": "node",
-	}).Info("Configured for " + types.NodeStringMap[node.Type] + " node")
+		"node":   n.name,
+	}).Info("Configured for " + types.NodeStringMap[nodeType] + " node")
+
+	return nodeType, nil
+}
 
-	return &node, nil
+// Name returns the node's name (host of the HTTP RPC endpoint) to be used in logs
+func (n *Node) Name() string {
+	return n.name
 }
 
 func (n *Node) makeHTTPRPCRequest(method string, params interface{}) (interface{}, error) {
@@ -98,7 +170,7 @@ func (n *Node) makeHTTPRPCRequest(method string, params interface{}) (interface{
 		Params:         params,
 	})
 
-	response, err := http.Post(n.httpRPCEndpoint, "application/json", bytes.NewBuffer(req))
+	response, err := httpClient.Post(n.httpRPCEndpoint, "application/json", bytes.NewBuffer(req))
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +204,7 @@ func (n *Node) HarvestBlockByNonce(givenNonceHex string, givenNumber uint64) (bl
 	for i := 0; i <= maxRecursion; i++ {
 		currentBlockNumber, err := n.BlockNumber()
 		if err != nil {
-			panic(err)
+			return Block{}, 0, errors.Wrap(err, "unable to get the current block number")
 		}
 
 		for i := 0; i <= blocksPerLoop; i++ {
@@ -0,0 +1,455 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package nodeapi
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/types"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// HealthCheckOptions specifies the node health checking parameters
+type HealthCheckOptions struct {
+	Interval    time.Duration
+	MinPeers    uint64
+	MaxBlockLag uint64
+}
+
+// NodeHealth is the result of the node's health check
+type NodeHealth struct {
+	Node        string     `json:"node"`
+	Reachable   bool       `json:"reachable"`
+	Healthy     bool       `json:"healthy"`
+	Syncing     SyncStatus `json:"syncing"`
+	PeerCount   uint64     `json:"peers"`
+	BlockNumber uint64     `json:"blockNumber"`
+}
+
+// NodePool is a set of upstream nodes. Work is taken from the primary (healthy) node,
+// and found blocks are broadcasted to all of them.
+type NodePool struct {
+	nodes              []*Node
+	health             []NodeHealth
+	primary            int
+	primaryChanged     chan struct{}
+	healthChanged      chan struct{}
+	mux                sync.RWMutex
+	healthCheckOptions HealthCheckOptions
+	engineWaitGroup    *sync.WaitGroup
+	context            context.Context
+	cancelContextFunc  context.CancelFunc
+}
+
+// NewNodePool creates a new NodePool instance (WebSocket RPC endpoints are optional, and are matched with HTTP ones by index)
+func NewNodePool(httpRPCEndpoints []string, wsRPCEndpoints []string, healthCheckOptions HealthCheckOptions, engineWaitGroup *sync.WaitGroup) (*NodePool, error) {
+	if len(httpRPCEndpoints) == 0 {
+		return nil, errors.New("no nodes specified")
+	}
+
+	if len(wsRPCEndpoints) != 0 && len(wsRPCEndpoints) != len(httpRPCEndpoints) {
+		return nil, errors.New("WebSocket RPC endpoints should be specified for every node")
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	pool := NodePool{
+		health:             make([]NodeHealth, len(httpRPCEndpoints)),
+		primaryChanged:     make(chan struct{}),
+		healthChanged:      make(chan struct{}),
+		healthCheckOptions: healthCheckOptions,
+		engineWaitGroup:    engineWaitGroup,
+		context:            ctx,
+		cancelContextFunc:  cancelFunc,
+	}
+
+	reachable := 0
+
+	for i, httpRPCEndpoint := range httpRPCEndpoints {
+		var wsRPCEndpoint string
+		if len(wsRPCEndpoints) != 0 {
+			wsRPCEndpoint = wsRPCEndpoints[i]
+		}
+
+		node, err := newNode(httpRPCEndpoint, wsRPCEndpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create node "+httpRPCEndpoint)
+		}
+
+		// Unreachable nodes are not fatal, their type is detected later by the health checker
+		if nodeType, err := node.detectType(); err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"prefix": "node",
+				"node":   node.Name(),
+				"error":  err,
+			}).Warn("Node is unreachable")
+		} else {
+			node.setType(nodeType)
+			reachable++
+		}
+
+		pool.nodes = append(pool.nodes, node)
+	}
+
+	if reachable == 0 {
+		return nil, errors.New("none of the nodes are reachable")
+	}
+
+	pool.checkHealth()
+
+	return &pool, nil
+}
+
+// Primary returns the node the work is currently taken from
+func (p *NodePool) Primary() *Node {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.nodes[p.primary]
+}
+
+// Type returns the type of the primary node
+func (p *NodePool) Type() types.NodeType {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.nodes[p.primary].Type()
+}
+
+// Types returns the distinct types of the detected nodes
+func (p *NodePool) Types() []types.NodeType {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	var nodeTypes []types.NodeType
+	seen := make(map[types.NodeType]bool)
+
+	for _, node := range p.nodes {
+		if nodeType, detected := node.detectedType(); detected && !seen[nodeType] {
+			seen[nodeType] = true
+			nodeTypes = append(nodeTypes, nodeType)
+		}
+	}
+
+	return nodeTypes
+}
+
+// Healthy returns true if the primary node is healthy, and its work can be handed out
+func (p *NodePool) Healthy() bool {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.health[p.primary].Healthy
+}
+
+// Health returns the last health check results of all nodes (the primary one goes first)
+func (p *NodePool) Health() []NodeHealth {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	health := []NodeHealth{p.health[p.primary]}
+	for i, h := range p.health {
+		if i != p.primary {
+			health = append(health, h)
+		}
+	}
+
+	return health
+}
+
+// HealthChanged returns a channel that is closed when the primary node becomes healthy or unhealthy
+func (p *NodePool) HealthChanged() <-chan struct{} {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.healthChanged
+}
+
+// HasWebSocket returns true if the nodes' WebSocket RPC endpoints are configured
+func (p *NodePool) HasWebSocket() bool {
+	return p.Primary().HasWebSocket()
+}
+
+// GetWork returns the current work of the primary node
+func (p *NodePool) GetWork() ([]string, error) {
+	return p.Primary().GetWork()
+}
+
+// BlockNumber returns the current block number of the primary node
+func (p *NodePool) BlockNumber() (uint64, error) {
+	return p.Primary().BlockNumber()
+}
+
+// CoinbaseBalance returns the balance (in wei) of the primary node's coinbase
+func (p *NodePool) CoinbaseBalance() (*big.Int, error) {
+	coinbase, err := p.Primary().Coinbase()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Primary().GetBalance(coinbase)
+}
+
+// ChainID returns the chain ID of the primary node
+func (p *NodePool) ChainID() (uint64, error) {
+	return p.Primary().ChainID()
+}
+
+// GetBlockByNumber returns block by number from the primary node
+func (p *NodePool) GetBlockByNumber(blockNumber uint64) (Block, error) {
+	return p.Primary().GetBlockByNumber(blockNumber)
+}
+
+// GetBlockByHash returns block by hash from the primary node
+func (p *NodePool) GetBlockByHash(blockHash string) (Block, error) {
+	return p.Primary().GetBlockByHash(blockHash)
+}
+
+// HarvestBlockByNonce finds the mined block using the primary node
+func (p *NodePool) HarvestBlockByNonce(givenNonceHex string, givenNumber uint64) (Block, uint64, error) {
+	return p.Primary().HarvestBlockByNonce(givenNonceHex, givenNumber)
+}
+
+// SubmitWork submits the work to all nodes at once. Only the node that created the work accepts it, and it might
+// not be the primary one anymore (so the block isn't lost on failover), but a block found on the work of a node
+// that went down is still lost. Returns true if at least one node has accepted the work.
+func (p *NodePool) SubmitWork(work []string) (bool, error) {
+	var wg sync.WaitGroup
+	statuses := make([]bool, len(p.nodes))
+	errs := make([]error, len(p.nodes))
+
+	for i, node := range p.nodes {
+		wg.Add(1)
+		go func(i int, node *Node) {
+			defer wg.Done()
+			statuses[i], errs[i] = node.SubmitWork(work)
+		}(i, node)
+	}
+
+	wg.Wait()
+
+	accepted := false
+	var lastErr error
+
+	for i, node := range p.nodes {
+		if errs[i] != nil {
+			lastErr = errs[i]
+			log.Logger.WithFields(logrus.Fields{
+				"prefix": "node",
+				"node":   node.Name(),
+				"error":  errs[i],
+			}).Error("Unable to submit work")
+			continue
+		}
+
+		// The work is accepted only by the node that created it
+		accepted = accepted || statuses[i]
+	}
+
+	if !accepted && lastErr != nil {
+		return false, lastErr
+	}
+
+	return accepted, nil
+}
+
+// SubscribeNewHeads subscribes to the primary node's new heads. The subscription is closed if the primary node changes.
+func (p *NodePool) SubscribeNewHeads(ctx context.Context, onNewHead func(blockNumber uint64)) error {
+	p.mux.RLock()
+	node := p.nodes[p.primary]
+	primaryChanged := p.primaryChanged
+	p.mux.RUnlock()
+
+	subscriptionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-primaryChanged:
+			cancel()
+		case <-subscriptionCtx.Done():
+		}
+	}()
+
+	err := node.SubscribeNewHeads(subscriptionCtx, onNewHead)
+	if err == nil && ctx.Err() == nil {
+		return errors.New("primary node has changed")
+	}
+
+	return err
+}
+
+// checkNode checks the health of the single node
+func (p *NodePool) checkNode(node *Node) (health NodeHealth) {
+	health.Node = node.Name()
+
+	if _, detected := node.detectedType(); !detected {
+		nodeType, err := node.detectType()
+		if err != nil {
+			return
+		}
+		node.setType(nodeType)
+	}
+
+	var err error
+
+	if health.Syncing, err = node.Syncing(); err != nil {
+		return
+	}
+
+	if health.PeerCount, err = node.PeerCount(); err != nil {
+		return
+	}
+
+	if health.BlockNumber, err = node.BlockNumber(); err != nil {
+		return
+	}
+
+	health.Reachable = true
+	return
+}
+
+// checkHealth checks all nodes, and switches the primary node if it became unhealthy
+func (p *NodePool) checkHealth() {
+	health := make([]NodeHealth, len(p.nodes))
+
+	var wg sync.WaitGroup
+	for i, node := range p.nodes {
+		wg.Add(1)
+		go func(i int, node *Node) {
+			defer wg.Done()
+			health[i] = p.checkNode(node)
+		}(i, node)
+	}
+	wg.Wait()
+
+	var highestBlockNumber uint64
+	for _, h := range health {
+		if h.Reachable && h.BlockNumber > highestBlockNumber {
+			highestBlockNumber = h.BlockNumber
+		}
+	}
+
+	for i := range health {
+		h := &health[i]
+		h.Healthy = h.Reachable && !h.Syncing.Status && h.PeerCount >= p.healthCheckOptions.MinPeers &&
+			highestBlockNumber-h.BlockNumber <= p.healthCheckOptions.MaxBlockLag
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	for i, node := range p.nodes {
+		if p.health[i].Healthy != health[i].Healthy || p.health[i].Reachable != health[i].Reachable {
+			fields := logrus.Fields{
+				"prefix":       "node",
+				"node":         node.Name(),
+				"reachable":    health[i].Reachable,
+				"syncing":      health[i].Syncing.Status,
+				"peers":        health[i].PeerCount,
+				"block-number": health[i].BlockNumber,
+			}
+
+			if health[i].Healthy {
+				log.Logger.WithFields(fields).Info("Node is healthy")
+			} else {
+				log.Logger.WithFields(fields).Warn("Node is unhealthy")
+			}
+		}
+	}
+
+	wasHealthy := p.health[p.primary].Healthy
+	p.health = health
+
+	defer func() {
+		// Primary node might have been switched, or its health might have changed
+		if p.health[p.primary].Healthy != wasHealthy {
+			close(p.healthChanged)
+			p.healthChanged = make(chan struct{})
+		}
+	}()
+
+	if health[p.primary].Healthy {
+		return
+	}
+
+	// Choosing the healthiest node: healthy ones first, then by the block number and the peer count
+	best := p.primary
+	for i, h := range health {
+		b := health[best]
+		if h.Healthy != b.Healthy {
+			if h.Healthy {
+				best = i
+			}
+			continue
+		}
+		if h.Reachable != b.Reachable {
+			if h.Reachable {
+				best = i
+			}
+			continue
+		}
+		if h.BlockNumber > b.BlockNumber || (h.BlockNumber == b.BlockNumber && h.PeerCount > b.PeerCount) {
+			best = i
+		}
+	}
+
+	if !health[best].Healthy {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "node",
+		}).Error("No healthy nodes available!")
+	}
+
+	if best == p.primary {
+		return
+	}
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix": "node",
+		"from":   p.nodes[p.primary].Name(),
+		"to":     p.nodes[best].Name(),
+	}).Warn("Switched primary node")
+
+	p.primary = best
+	close(p.primaryChanged)
+	p.primaryChanged = make(chan struct{})
+}
+
+// Run runs the node health checker
+func (p *NodePool) Run() {
+	p.engineWaitGroup.Add(1)
+	defer p.engineWaitGroup.Done()
+
+	ticker := time.NewTicker(p.healthCheckOptions.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.context.Done():
+			return
+		case <-ticker.C:
+			p.checkHealth()
+		}
+	}
+}
+
+// Stop stops the node health checker
+func (p *NodePool) Stop() {
+	p.cancelContextFunc()
+}
@@ -0,0 +1,119 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package nodeapi
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/flexpool/solo/jsonrpc"
+	"github.com/flexpool/solo/utils"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/websocket"
+)
+
+const webSocketReadTimeout = time.Minute * 2 // Blocks are expected to arrive much more often
+
+// subscriptionMessage represents both `eth_subscribe` response and `eth_subscription` notification
+type subscriptionMessage struct {
+	ID     int         `json:"id"`
+	Result interface{} `json:"result"`
+	Error  interface{} `json:"error"`
+	Method string      `json:"method"`
+	Params struct {
+		Subscription string `json:"subscription"`
+		Result       struct {
+			Number string `json:"number"`
+			Hash   string `json:"hash"`
+		} `json:"result"`
+	} `json:"params"`
+}
+
+// HasWebSocket returns true if the node's WebSocket RPC endpoint is configured
+func (n *Node) HasWebSocket() bool {
+	return n.wsRPCEndpoint != ""
+}
+
+// SubscribeNewHeads subscribes to the `newHeads` events via WebSocket RPC, and calls onNewHead for every new block.
+// The function blocks until the context is cancelled, or the connection fails.
+func (n *Node) SubscribeNewHeads(ctx context.Context, onNewHead func(blockNumber uint64)) error {
+	ws, err := websocket.Dial(n.wsRPCEndpoint, "", "http://localhost/")
+	if err != nil {
+		return errors.Wrap(err, "unable to connect to the node's WebSocket")
+	}
+
+	// Closing the connection unblocks the receiver
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		ws.Close()
+	}()
+
+	requestID := rand.Intn(99999999)
+	_, err = ws.Write(jsonrpc.MarshalRequest(jsonrpc.Request{
+		JSONRPCVersion: jsonrpc.Version,
+		ID:             requestID,
+		Method:         "eth_subscribe",
+		Params:         []string{"newHeads"},
+	}))
+	if err != nil {
+		return errors.Wrap(err, "unable to send eth_subscribe request")
+	}
+
+	var subscriptionID string
+
+	for {
+		ws.SetReadDeadline(time.Now().Add(webSocketReadTimeout))
+
+		var message subscriptionMessage
+		err := websocket.JSON.Receive(ws, &message)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "unable to receive WebSocket message")
+		}
+
+		if subscriptionID == "" {
+			if message.ID != requestID {
+				continue
+			}
+
+			if message.Error != nil {
+				return errors.New("unexpected eth_subscribe response")
+			}
+
+			subscriptionID, _ = message.Result.(string)
+			if subscriptionID == "" {
+				return errors.New("unexpected eth_subscribe response")
+			}
+			continue
+		}
+
+		if message.Method != "eth_subscription" || message.Params.Subscription != subscriptionID {
+			continue
+		}
+
+		onNewHead(utils.MustSoftHexToUint64(message.Params.Result.Number))
+	}
+}
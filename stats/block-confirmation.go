@@ -34,7 +34,7 @@ import (
 // BlockConfirmationManager is a daemon that confirms blocks by verifying its type (block/uncle/orphan)
 type BlockConfirmationManager struct {
 	Database              *db.Database
-	Node                  *nodeapi.Node
+	Node                  *nodeapi.NodePool
 	Context               context.Context
 	ContextCancelFunc     context.CancelFunc
 	engineWaitGroup       *sync.WaitGroup
@@ -42,7 +42,7 @@ type BlockConfirmationManager struct {
 }
 
 // NewBlockConfirmationManager creates a new BlockConfirmationManager instance
-func NewBlockConfirmationManager(database *db.Database, engineWaitGroup *sync.WaitGroup, node *nodeapi.Node, confirmationsRequired uint64) *BlockConfirmationManager {
+func NewBlockConfirmationManager(database *db.Database, engineWaitGroup *sync.WaitGroup, node *nodeapi.NodePool, confirmationsRequired uint64) *BlockConfirmationManager {
 	ctx, contextCancelFunc := context.WithCancel(context.Background())
 
 	return &BlockConfirmationManager{
@@ -106,8 +106,7 @@ func (b *BlockConfirmationManager) Run() {
 					}
 
 					parsedBlock.Confirmed = true
-					blockBytes, _ := msgpack.Marshal(parsedBlock)
-					if b.Database.DB.Put(iter.Key(), blockBytes, nil) != nil {
+					if err := b.Database.WriteMinedBlock(parsedBlock); err != nil {
 						log.Logger.WithFields(logrus.Fields{
 							"prefix": "blockmanager",
 							"error":  err,
@@ -0,0 +1,107 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package stats
+
+import (
+	"time"
+
+	"github.com/flexpool/solo/db"
+	"github.com/flexpool/solo/log"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const checkpointPeriodSecs = 60 // Checkpoint pending stats every minute
+
+// pendingStatsCheckpoint is a snapshot of the PendingStats, persisted so they survive restarts
+type pendingStatsCheckpoint struct {
+	CollectionTimestamp int64                  `msgpack:"collection_timestamp"` // The collection the stats will be written at
+	PendingStats        map[string]PendingStat `msgpack:"pending_stats"`
+}
+
+// nextCollectionTimestamp returns the timestamp the currently pending stats will be collected at
+func (c *Collector) nextCollectionTimestamp(now int64) int64 {
+	statCollectionPeriodSecs := c.Database.StatsOptions.CollectionPeriodSecs
+	return (now/statCollectionPeriodSecs + 1) * statCollectionPeriodSecs
+}
+
+// Checkpoint writes the PendingStats to the database
+func (c *Collector) Checkpoint() error {
+	c.Mux.Lock()
+	data, err := msgpack.Marshal(pendingStatsCheckpoint{
+		CollectionTimestamp: c.nextCollectionTimestamp(time.Now().Unix()),
+		PendingStats:        c.PendingStats,
+	})
+	c.Mux.Unlock()
+
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal pending stats")
+	}
+
+	return c.Database.DB.Put([]byte(db.PendingStatsKey), data, nil)
+}
+
+// deleteCheckpointInBatch removes the checkpoint along with the collected stats, so they are never restored twice
+func deleteCheckpointInBatch(batch *leveldb.Batch) {
+	batch.Delete([]byte(db.PendingStatsKey))
+}
+
+// restoreCheckpoint loads the PendingStats from the database, if the checkpoint belongs to the current collection period
+func (c *Collector) restoreCheckpoint() {
+	data, err := c.Database.DB.Get([]byte(db.PendingStatsKey), nil)
+	if err == leveldb.ErrNotFound {
+		return
+	}
+
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "stats",
+			"error":  err,
+		}).Error("Unable to read pending stats checkpoint")
+		return
+	}
+
+	var checkpoint pendingStatsCheckpoint
+	if err := msgpack.Unmarshal(data, &checkpoint); err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "stats",
+			"error":  err,
+		}).Error("Pending stats checkpoint is corrupted")
+		return
+	}
+
+	if checkpoint.CollectionTimestamp != c.nextCollectionTimestamp(time.Now().Unix()) {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix":               "stats",
+			"collection-timestamp": checkpoint.CollectionTimestamp,
+		}).Warn("Discarded outdated pending stats checkpoint")
+		return
+	}
+
+	c.Mux.Lock()
+	for workerName, pendingStat := range checkpoint.PendingStats {
+		c.PendingStats[workerName] = pendingStat
+	}
+	c.Mux.Unlock()
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":  "stats",
+		"workers": len(checkpoint.PendingStats),
+	}).Info("Restored pending stats checkpoint")
+}
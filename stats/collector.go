@@ -29,15 +29,14 @@ import (
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
-const statCollectionPeriodSecs = 600 // Collect stats every 10 minutes
-const keepStatsForSecs = 86400       // Keep stats for one day
-
 // Collector is a stat collection daemon struct
 type Collector struct {
 	// map[<worker-name>]PendingStat
 	PendingStats map[string]PendingStat
 
-	ShareDifficulty   uint64
+	// Live hashrate, independent of the collection period
+	Hashrate *HashrateEstimator
+
 	Database          *db.Database
 	Context           context.Context
 	ContextCancelFunc context.CancelFunc
@@ -62,16 +61,17 @@ func (c *Collector) Clear() {
 }
 
 // NewCollector creates a new Stats Collector
-func NewCollector(database *db.Database, engineWaitGroup *sync.WaitGroup, shareDifficulty uint64) *Collector {
+func NewCollector(database *db.Database, engineWaitGroup *sync.WaitGroup) *Collector {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	c := Collector{
 		Context:           ctx,
 		ContextCancelFunc: cancelFunc,
 		engineWaitGroup:   engineWaitGroup,
 		Database:          database,
-		ShareDifficulty:   shareDifficulty,
+		Hashrate:          NewHashrateEstimator(),
 	}
 	c.Init()
+	c.restoreCheckpoint()
 	return &c
 }
 
@@ -81,6 +81,7 @@ func (c *Collector) Run() {
 	c.engineWaitGroup.Add(1)
 	defer c.engineWaitGroup.Done()
 
+	statCollectionPeriodSecs := c.Database.StatsOptions.CollectionPeriodSecs
 	prevCollectionTimestamp := time.Now().Unix() / statCollectionPeriodSecs * statCollectionPeriodSecs
 
 	log.Logger.WithFields(logrus.Fields{
@@ -89,9 +90,18 @@ func (c *Collector) Run() {
 
 	var totalCollectedHashrate float64
 
+	lastCheckpointTimestamp := time.Now().Unix()
+
 	for {
 		select {
 		case <-c.Context.Done():
+			// Gateways are already stopped, so no more shares are accounted after this
+			if err := c.Checkpoint(); err != nil {
+				log.Logger.WithFields(logrus.Fields{
+					"prefix": "stats",
+					"error":  err,
+				}).Error("Unable to checkpoint pending stats")
+			}
 			log.Logger.WithFields(logrus.Fields{
 				"prefix": "stats",
 			}).Info("Stopped Stats Collector")
@@ -99,6 +109,16 @@ func (c *Collector) Run() {
 		default:
 			currentCollectionTimestamp := time.Now().Unix() / statCollectionPeriodSecs * statCollectionPeriodSecs // Get rid of remainder
 			if prevCollectionTimestamp == currentCollectionTimestamp {
+				if time.Now().Unix()-lastCheckpointTimestamp >= checkpointPeriodSecs {
+					lastCheckpointTimestamp = time.Now().Unix()
+					if err := c.Checkpoint(); err != nil {
+						log.Logger.WithFields(logrus.Fields{
+							"prefix": "stats",
+							"error":  err,
+						}).Error("Unable to checkpoint pending stats")
+					}
+				}
+
 				time.Sleep(time.Second)
 				continue
 			}
@@ -112,21 +132,24 @@ func (c *Collector) Run() {
 			timestamp := time.Now().Unix() / statCollectionPeriodSecs * statCollectionPeriodSecs // Get rid of remainder
 
 			for workerName, pendingStat := range c.PendingStats {
-				effectiveHashrate := float64(pendingStat.ValidShares) * float64(c.ShareDifficulty)
-				totalCollectedHashrate += effectiveHashrate / statCollectionPeriodSecs
+				// Every share is weighted by its own difficulty
+				effectiveHashrate := pendingStat.ValidSharesDifficulty / float64(statCollectionPeriodSecs)
+				totalCollectedHashrate += effectiveHashrate
 				stat := db.Stat{
-					WorkerName:        workerName,
-					ValidShareCount:   pendingStat.ValidShares,
-					StaleShareCount:   pendingStat.StaleShares,
-					InvalidShareCount: pendingStat.InvalidShares,
-					ReportedHashrate:  pendingStat.ReportedHashrate,
-					EffectiveHashrate: effectiveHashrate,
-					IPAddress:         pendingStat.IPAddress,
+					WorkerName:          workerName,
+					ValidShareCount:     pendingStat.ValidShares,
+					StaleShareCount:     pendingStat.StaleShares,
+					InvalidShareCount:   pendingStat.InvalidShares,
+					DuplicateShareCount: pendingStat.DuplicateShares,
+					ReportedHashrate:    pendingStat.ReportedHashrate,
+					EffectiveHashrate:   effectiveHashrate,
+					IPAddress:           pendingStat.IPAddress,
 				}
 
 				pendingTotalStat.ValidShareCount += pendingStat.ValidShares
 				pendingTotalStat.StaleShareCount += pendingStat.StaleShares
 				pendingTotalStat.InvalidShareCount += pendingStat.InvalidShares
+				pendingTotalStat.DuplicateShareCount += pendingStat.DuplicateShares
 				pendingTotalStat.EffectiveHashrate += effectiveHashrate
 				pendingTotalStat.ReportedHashrate += pendingStat.ReportedHashrate
 				pendingTotalStat.WorkerCount++
@@ -138,6 +161,7 @@ func (c *Collector) Run() {
 			c.Clear()
 
 			db.WriteTotalStatToBatch(batch, pendingTotalStat, timestamp)
+			deleteCheckpointInBatch(batch)
 
 			c.Database.DB.Write(batch, nil)
 
@@ -148,7 +172,8 @@ func (c *Collector) Run() {
 			totalCollectedHashrate = 0
 
 			c.Database.GetAndWriteCachedValues()
-			c.Database.PruneStats(keepStatsForSecs)
+			c.Database.PruneStats(c.Database.StatsOptions.RetentionSecs)
+			c.Hashrate.Prune()
 		}
 	}
 }
@@ -0,0 +1,210 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package stats
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HashrateWindows are the sliding windows the live hashrate is estimated over
+var HashrateWindows = []time.Duration{time.Minute * 5, time.Hour, time.Hour * 24}
+
+const hashrateWindowSlots = 60 // Every window is split into this many slots
+
+// slidingWindow sums the share difficulties submitted within the window.
+// The ring has one extra slot, so the oldest one can be weighted by the part of it still within the window.
+type slidingWindow struct {
+	slotDuration int64
+	slots        [hashrateWindowSlots + 1]float64
+	lastSlot     int64
+}
+
+func newSlidingWindow(window time.Duration) *slidingWindow {
+	return &slidingWindow{slotDuration: int64(window) / hashrateWindowSlots}
+}
+
+// advance zeroes the slots that have passed since the last update
+func (w *slidingWindow) advance(slot int64) {
+	if slot <= w.lastSlot {
+		return
+	}
+
+	if slot-w.lastSlot > int64(len(w.slots)) {
+		w.slots = [hashrateWindowSlots + 1]float64{}
+	} else {
+		for s := w.lastSlot + 1; s <= slot; s++ {
+			w.slots[s%int64(len(w.slots))] = 0
+		}
+	}
+
+	w.lastSlot = slot
+}
+
+func (w *slidingWindow) add(now time.Time, difficulty float64) {
+	slot := now.UnixNano() / w.slotDuration
+	w.advance(slot)
+	w.slots[slot%int64(len(w.slots))] += difficulty
+}
+
+func (w *slidingWindow) sum(now time.Time) float64 {
+	slot := now.UnixNano() / w.slotDuration
+	w.advance(slot)
+
+	var sum float64
+	for _, difficulty := range w.slots {
+		sum += difficulty
+	}
+
+	oldestSlot := (slot + 1) % int64(len(w.slots))
+	elapsed := float64(now.UnixNano()%w.slotDuration) / float64(w.slotDuration)
+	return sum - w.slots[oldestSlot]*elapsed
+}
+
+// shareRate holds the sliding windows of a single worker (or the total)
+type shareRate struct {
+	windows   []*slidingWindow
+	lastShare time.Time
+}
+
+func newShareRate() *shareRate {
+	r := shareRate{}
+	for _, window := range HashrateWindows {
+		r.windows = append(r.windows, newSlidingWindow(window))
+	}
+	return &r
+}
+
+// HashrateEstimator estimates the live effective hashrate from the accepted shares, weighted by their difficulty
+type HashrateEstimator struct {
+	startedAt time.Time
+	total     *shareRate
+	workers   map[string]*shareRate
+	mux       sync.Mutex
+}
+
+// NewHashrateEstimator creates a new HashrateEstimator
+func NewHashrateEstimator() *HashrateEstimator {
+	return &HashrateEstimator{
+		startedAt: time.Now(),
+		total:     newShareRate(),
+		workers:   make(map[string]*shareRate),
+	}
+}
+
+// RecordShare adds the accepted share to the total and the worker's hashrate
+func (e *HashrateEstimator) RecordShare(workerName string, shareDifficulty uint64) {
+	now := time.Now()
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	worker, ok := e.workers[workerName]
+	if !ok {
+		worker = newShareRate()
+		e.workers[workerName] = worker
+	}
+
+	for _, rate := range []*shareRate{e.total, worker} {
+		for _, window := range rate.windows {
+			window.add(now, float64(shareDifficulty))
+		}
+		rate.lastShare = now
+	}
+}
+
+// hashrate returns the rate's hashrate (H/s) per window name, windows longer than the uptime are averaged over the uptime
+func (e *HashrateEstimator) hashrate(rate *shareRate, now time.Time) map[string]float64 {
+	uptime := now.Sub(e.startedAt)
+	result := make(map[string]float64)
+
+	for i, window := range HashrateWindows {
+		period := window
+		if uptime < period {
+			period = uptime
+		}
+
+		var hashrate float64
+		if rate != nil && period > 0 {
+			hashrate = rate.windows[i].sum(now) / period.Seconds()
+		}
+		result[hashrateWindowName(window)] = hashrate
+	}
+
+	return result
+}
+
+// Hashrate returns the total live hashrate per window
+func (e *HashrateEstimator) Hashrate() map[string]float64 {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.hashrate(e.total, time.Now())
+}
+
+// WorkerHashrate returns the worker's live hashrate per window
+func (e *HashrateEstimator) WorkerHashrate(workerName string) map[string]float64 {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.hashrate(e.workers[workerName], time.Now())
+}
+
+// WorkersHashrate returns the live hashrate per window of every worker
+func (e *HashrateEstimator) WorkersHashrate() map[string]map[string]float64 {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	now := time.Now()
+	result := make(map[string]map[string]float64)
+	for workerName, rate := range e.workers {
+		result[workerName] = e.hashrate(rate, now)
+	}
+	return result
+}
+
+// WorkersLastShare returns the time of the last accepted share of every worker
+func (e *HashrateEstimator) WorkersLastShare() map[string]time.Time {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	result := make(map[string]time.Time)
+	for workerName, rate := range e.workers {
+		result[workerName] = rate.lastShare
+	}
+	return result
+}
+
+// Prune removes the workers without shares within the longest window
+func (e *HashrateEstimator) Prune() {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	longestWindow := HashrateWindows[len(HashrateWindows)-1]
+	for workerName, rate := range e.workers {
+		if time.Since(rate.lastShare) > longestWindow {
+			delete(e.workers, workerName)
+		}
+	}
+}
+
+// hashrateWindowName formats the window as "5m", "1h", etc.
+func hashrateWindowName(window time.Duration) string {
+	if window%time.Hour == 0 {
+		return strconv.FormatInt(int64(window/time.Hour), 10) + "h"
+	}
+	return strconv.FormatInt(int64(window/time.Minute), 10) + "m"
+}
@@ -0,0 +1,89 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowEdgeWeighting(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	w := newSlidingWindow(time.Minute) // One second slots
+	w.add(start, 100)
+
+	tests := []struct {
+		offset time.Duration
+		want   float64
+	}{
+		{0, 100},
+		{30 * time.Second, 100},
+		{60 * time.Second, 100}, // The share slot is the oldest one, still fully within the window
+		{60*time.Second + 250*time.Millisecond, 75}, // A quarter of it has left the window
+		{60*time.Second + 750*time.Millisecond, 25},
+		{61 * time.Second, 0},
+		{time.Hour, 0},
+	}
+
+	for _, test := range tests {
+		if sum := w.sum(start.Add(test.offset)); math.Abs(sum-test.want) > 1e-9 {
+			t.Errorf("offset %v: expected sum %v, got %v", test.offset, test.want, sum)
+		}
+	}
+}
+
+func TestSlidingWindowSteadyRate(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	w := newSlidingWindow(time.Minute)
+
+	// Two shares per second, the window always holds about 120 of them
+	for i := 0; i < 400; i++ {
+		now := start.Add(time.Duration(i) * 500 * time.Millisecond)
+		w.add(now, 1)
+
+		if i < 120 {
+			continue
+		}
+		for _, offset := range []time.Duration{0, 100 * time.Millisecond, 400 * time.Millisecond} {
+			if sum := w.sum(now.Add(offset)); math.Abs(sum-120) > 1 {
+				t.Fatalf("share %d, offset %v: expected sum of about 120, got %v", i, offset, sum)
+			}
+		}
+	}
+}
+
+func TestHashrateEstimatorUptime(t *testing.T) {
+	e := NewHashrateEstimator()
+	e.startedAt = time.Now().Add(-30 * time.Second)
+	e.RecordShare("worker", 300)
+
+	now := time.Now()
+	want := map[string]float64{"5m": 10, "1h": 10, "24h": 10} // Averaged over the 30s uptime
+	hashrate := e.hashrate(e.workers["worker"], now)
+	for window, wantHashrate := range want {
+		if math.Abs(hashrate[window]-wantHashrate) > 0.1 {
+			t.Errorf("window %s: expected hashrate %v, got %v", window, wantHashrate, hashrate[window])
+		}
+	}
+
+	for window, unknownHashrate := range e.hashrate(nil, now) {
+		if unknownHashrate != 0 {
+			t.Errorf("window %s: expected zero hashrate of the unknown worker, got %v", window, unknownHashrate)
+		}
+	}
+}
@@ -18,9 +18,11 @@ package stats
 
 // PendingStat is a pending Stat struct
 type PendingStat struct {
-	ValidShares      uint64
-	StaleShares      uint64
-	InvalidShares    uint64
-	ReportedHashrate float64
-	IPAddress        string
+	ValidShares           uint64  `msgpack:"valid_shares"`
+	StaleShares           uint64  `msgpack:"stale_shares"`
+	InvalidShares         uint64  `msgpack:"invalid_shares"`
+	DuplicateShares       uint64  `msgpack:"duplicate_shares"`
+	ValidSharesDifficulty float64 `msgpack:"valid_shares_difficulty"` // Sum of the valid shares difficulties
+	ReportedHashrate      float64 `msgpack:"reported_hashrate"`
+	IPAddress             string  `msgpack:"ip_address"`
 }
@@ -0,0 +1,36 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package types
+
+// StratumProtocol is a shortcut for uint8, and is used to store information about which stratum dialect the connection speaks
+type StratumProtocol uint8
+
+const (
+	// EthProxyProtocol specifies StratumProtocol for EthProxy (eth_submitLogin / eth_getWork / eth_submitWork)
+	EthProxyProtocol = 0
+	// EthereumStratumProtocol specifies StratumProtocol for NiceHash EthereumStratum/1.0.0 (mining.subscribe / mining.notify / mining.submit)
+	EthereumStratumProtocol = 1
+	// GetworkProtocol specifies StratumProtocol for plain HTTP getwork (JSONRPC over HTTP POST)
+	GetworkProtocol = 2
+)
+
+// StratumProtocolNameMap is a mapping that allows to quickly access the name of stratum protocol
+var StratumProtocolNameMap = map[StratumProtocol]string{
+	EthProxyProtocol:        "EthProxy",
+	EthereumStratumProtocol: "EthereumStratum/1.0.0",
+	GetworkProtocol:         "Getwork",
+}
@@ -26,6 +26,8 @@ const (
 	ShareStale = 1
 	// ShareInvalid is a shortcut for uint8(2)
 	ShareInvalid = 2
+	// ShareDuplicate is a shortcut for uint8(3)
+	ShareDuplicate = 3
 )
 
 // ShareTypeNameMap has ShareType => <Share name (String)> mapping
@@ -33,4 +35,5 @@ var ShareTypeNameMap = map[ShareType]string{
 	0: "valid",
 	1: "stale",
 	2: "invalid",
+	3: "duplicate",
 }
@@ -2,7 +2,7 @@ package utils
 
 import "time"
 
-// GetCurrent10MinTimestamp returns current timestamp without other 10 min remainder
-func GetCurrent10MinTimestamp() int64 {
-	return time.Now().Unix() / 600 * 600
+// GetCurrentPeriodTimestamp returns current timestamp without the remainder of the given period
+func GetCurrentPeriodTimestamp(periodSecs int64) int64 {
+	return time.Now().Unix() / periodSecs * periodSecs
 }
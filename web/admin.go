@@ -0,0 +1,287 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flexpool/solo/db"
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// workerCredentialRequest is the admin API request to add a worker
+type workerCredentialRequest struct {
+	NamePattern string   `json:"namePattern"`
+	Password    string   `json:"password"`
+	AllowedIPs  []string `json:"allowedIPs"`
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, result interface{}, err interface{}) {
+	data, marshalErr := MarshalAPIResponse(APIResponse{
+		Result: result,
+		Error:  err,
+	})
+	if marshalErr != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "web",
+			"error":  marshalErr,
+		}).Error("Unable to marshal API response")
+
+		status = http.StatusInternalServerError
+		data, _ = MarshalAPIResponse(APIResponse{
+			Result: nil,
+			Error:  "Unable to marshal response",
+		})
+	}
+
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// adminAuthenticated checks the admin API token (the admin API is disabled if the token is not configured)
+func (a *Server) adminAuthenticated(w http.ResponseWriter, r *http.Request) bool {
+	if a.adminToken == "" {
+		writeAPIResponse(w, http.StatusNotFound, nil, "Admin API is disabled")
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+		writeAPIResponse(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return false
+	}
+
+	return true
+}
+
+// governedSessions returns the IDs of the live sessions authenticated with the credential of the name pattern
+func (a *Server) governedSessions(namePattern string) []uint64 {
+	var ids []uint64
+	for _, session := range a.sessionRegistry.Sessions() {
+		if a.credentials.Governs(namePattern, session.WorkerName) {
+			ids = append(ids, session.ID)
+		}
+	}
+	return ids
+}
+
+// disconnectSessions disconnects the sessions of the revoked credential
+func (a *Server) disconnectSessions(ids []uint64) {
+	for _, id := range ids {
+		a.sessionRegistry.Disconnect(id)
+	}
+}
+
+// handleAdminWorkers lists (GET), adds (POST) and removes (DELETE) the worker credentials
+func (a *Server) handleAdminWorkers(w http.ResponseWriter, r *http.Request) {
+	if !a.adminAuthenticated(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeAPIResponse(w, http.StatusOK, a.credentials.Credentials(), nil)
+	case http.MethodPost:
+		var request workerCredentialRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid request body")
+			return
+		}
+
+		if request.NamePattern == "" || request.Password == "" {
+			writeAPIResponse(w, http.StatusBadRequest, nil, "namePattern and password are required")
+			return
+		}
+
+		if _, err := path.Match(request.NamePattern, ""); err != nil {
+			writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid namePattern")
+			return
+		}
+
+		if _, err := utils.ParseIPNetworks(request.AllowedIPs); err != nil {
+			writeAPIResponse(w, http.StatusBadRequest, nil, processError(err))
+			return
+		}
+
+		passwordHash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+			return
+		}
+
+		credential := db.WorkerCredential{
+			NamePattern:  request.NamePattern,
+			PasswordHash: string(passwordHash),
+			Enabled:      true,
+			AllowedIPs:   request.AllowedIPs,
+			CreatedAt:    time.Now().Unix(),
+		}
+
+		if err := a.credentials.Write(credential); err != nil {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+			return
+		}
+
+		log.Logger.WithFields(logrus.Fields{
+			"prefix":       "web",
+			"name-pattern": credential.NamePattern,
+		}).Info("Added worker credential")
+
+		writeAPIResponse(w, http.StatusOK, credential, nil)
+	case http.MethodDelete:
+		namePattern := r.URL.Query().Get("namePattern")
+		revokedSessions := a.governedSessions(namePattern)
+		err := a.credentials.Delete(namePattern)
+		if err == leveldb.ErrNotFound {
+			writeAPIResponse(w, http.StatusNotFound, nil, "Worker not found")
+			return
+		} else if err != nil {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+			return
+		}
+
+		a.disconnectSessions(revokedSessions)
+
+		log.Logger.WithFields(logrus.Fields{
+			"prefix":       "web",
+			"name-pattern": namePattern,
+			"sessions":     len(revokedSessions),
+		}).Info("Removed worker credential")
+
+		writeAPIResponse(w, http.StatusOK, true, nil)
+	default:
+		writeAPIResponse(w, http.StatusMethodNotAllowed, nil, "Method not allowed")
+	}
+}
+
+// handleAdminWorkerState returns a handler that enables or disables the worker credential (POST)
+func (a *Server) handleAdminWorkerState(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.adminAuthenticated(w, r) {
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			writeAPIResponse(w, http.StatusMethodNotAllowed, nil, "Method not allowed")
+			return
+		}
+
+		namePattern := r.URL.Query().Get("namePattern")
+		credential, ok := a.credentials.Credential(namePattern)
+		if !ok {
+			writeAPIResponse(w, http.StatusNotFound, nil, "Worker not found")
+			return
+		}
+
+		var revokedSessions []uint64
+		if !enabled {
+			revokedSessions = a.governedSessions(namePattern)
+		}
+
+		credential.Enabled = enabled
+		if err := a.credentials.Write(credential); err != nil {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+			return
+		}
+
+		a.disconnectSessions(revokedSessions)
+
+		log.Logger.WithFields(logrus.Fields{
+			"prefix":       "web",
+			"name-pattern": namePattern,
+			"enabled":      enabled,
+			"sessions":     len(revokedSessions),
+		}).Info("Changed worker credential state")
+
+		writeAPIResponse(w, http.StatusOK, credential, nil)
+	}
+}
+
+// handleAdminBans lists (GET) and removes (DELETE) the gateway IP bans
+func (a *Server) handleAdminBans(w http.ResponseWriter, r *http.Request) {
+	if !a.adminAuthenticated(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeAPIResponse(w, http.StatusOK, a.banManager.Bans(), nil)
+	case http.MethodDelete:
+		found, err := a.banManager.Unban(r.URL.Query().Get("ip"))
+		if err != nil {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+			return
+		} else if !found {
+			writeAPIResponse(w, http.StatusNotFound, nil, "IP is not banned")
+			return
+		}
+
+		writeAPIResponse(w, http.StatusOK, true, nil)
+	default:
+		writeAPIResponse(w, http.StatusMethodNotAllowed, nil, "Method not allowed")
+	}
+}
+
+// handleSessions lists the live stratum sessions (admin only, since the sessions expose the miners' addresses)
+func (a *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if !a.adminAuthenticated(w, r) {
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, a.sessionRegistry.Sessions(), nil)
+}
+
+// handleAdminDisconnectSession disconnects the stratum session by its ID (POST)
+func (a *Server) handleAdminDisconnectSession(w http.ResponseWriter, r *http.Request) {
+	if !a.adminAuthenticated(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeAPIResponse(w, http.StatusMethodNotAllowed, nil, "Method not allowed")
+		return
+	}
+
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid session ID")
+		return
+	}
+
+	if !a.sessionRegistry.Disconnect(id) {
+		writeAPIResponse(w, http.StatusNotFound, nil, "Session not found")
+		return
+	}
+
+	log.Logger.WithFields(logrus.Fields{
+		"prefix":     "web",
+		"session-id": id,
+	}).Info("Disconnected session")
+
+	writeAPIResponse(w, http.StatusOK, true, nil)
+}
@@ -0,0 +1,12 @@
+module.exports = {
+  // The built dashboard is embedded into the solo binary
+  outputDir: "../dashboard/dist",
+  devServer: {
+    // The API is served on the same origin in production, proxying it to the mock API for development
+    proxy: {
+      "/api": {
+        target: "http://localhost:8000",
+      },
+    },
+  },
+};
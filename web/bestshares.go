@@ -0,0 +1,50 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const defaultBestSharesRounds = 10
+const maxBestSharesRounds = 100
+
+// handleBestShares returns the best shares of the latest rounds, optionally of a single worker
+func (a *Server) handleBestShares(w http.ResponseWriter, r *http.Request) {
+	limit := defaultBestSharesRounds
+	if roundsParam := r.URL.Query().Get("rounds"); roundsParam != "" {
+		rounds, err := strconv.Atoi(roundsParam)
+		if err != nil || rounds <= 0 || rounds > maxBestSharesRounds {
+			writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid rounds (expected 1-"+strconv.Itoa(maxBestSharesRounds)+")")
+			return
+		}
+		limit = rounds
+	}
+
+	rounds, err := a.database.GetBestSharesByRound(r.URL.Query().Get("workerName"))
+	if err != nil {
+		writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+		return
+	}
+
+	if len(rounds) > limit {
+		rounds = rounds[:limit]
+	}
+
+	writeAPIResponse(w, http.StatusOK, rounds, nil)
+}
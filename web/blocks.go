@@ -0,0 +1,98 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/flexpool/solo/db"
+)
+
+const defaultBlocksPageLimit = 10
+const maxBlocksPageLimit = 100
+
+// handleBlocks returns the page of the mined blocks (sorted, filtered and paginated by the query parameters)
+func (a *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := db.BlockQuery{
+		Limit:      defaultBlocksPageLimit,
+		Type:       params.Get("type"),
+		WorkerName: params.Get("workerName"),
+	}
+
+	switch params.Get("sortBy") {
+	case "", "number":
+	case "time":
+		query.SortByTime = true
+	default:
+		writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid sortBy (expected number or time)")
+		return
+	}
+
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		query.Ascending = true
+	default:
+		writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid order (expected asc or desc)")
+		return
+	}
+
+	switch query.Type {
+	case "", "block", "uncle", "orphan":
+	default:
+		writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid type (expected block, uncle or orphan)")
+		return
+	}
+
+	if confirmedParam := params.Get("confirmed"); confirmedParam != "" {
+		confirmed, err := strconv.ParseBool(confirmedParam)
+		if err != nil {
+			writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid confirmed")
+			return
+		}
+		query.Confirmed = &confirmed
+	}
+
+	if limitParam := params.Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit <= 0 || limit > maxBlocksPageLimit {
+			writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid limit (expected 1-"+strconv.Itoa(maxBlocksPageLimit)+")")
+			return
+		}
+		query.Limit = limit
+	}
+
+	if pageParam := params.Get("page"); pageParam != "" {
+		page, err := strconv.Atoi(pageParam)
+		if err != nil || page < 0 {
+			writeAPIResponse(w, http.StatusBadRequest, nil, "Invalid page")
+			return
+		}
+		query.Offset = page * query.Limit
+	}
+
+	blocks, err := a.database.GetBlocks(query)
+	if err != nil {
+		writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, blocks, nil)
+}
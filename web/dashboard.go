@@ -0,0 +1,53 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// dashboardFS holds the built Vue dashboard (web/app is built into dashboard/dist)
+//
+//go:embed dashboard
+var dashboardFS embed.FS
+
+// dashboardHandler serves the embedded dashboard, or a placeholder page if the dashboard wasn't built
+func dashboardHandler() http.Handler {
+	dist, err := fs.Sub(dashboardFS, "dashboard/dist")
+	if err == nil {
+		if _, err := fs.Stat(dist, "index.html"); err == nil {
+			fileServer := http.FileServer(http.FS(dist))
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The dashboard uses the history mode routing, so unknown paths are served the index page
+				if _, err := fs.Stat(dist, strings.TrimPrefix(path.Clean(r.URL.Path), "/")); err != nil {
+					r.URL.Path = "/"
+				}
+				fileServer.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	placeholder, _ := dashboardFS.ReadFile("dashboard/placeholder.html")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(placeholder)
+	})
+}
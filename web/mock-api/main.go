@@ -33,6 +33,7 @@ type worker struct {
 	Stale     float64 `json:"staleShares"`
 	Invalid   float64 `json:"invalidShares"`
 	LastSeen  int64   `json:"lastSeen"`
+	Online    bool    `json:"online"`
 }
 
 const shareDifficulty float64 = 4000000000
@@ -84,6 +85,7 @@ func genRandomWorker(online bool) worker {
 		Stale:     staleShares,
 		Invalid:   invalidShares,
 		LastSeen:  lastSeen,
+		Online:    online,
 	}
 }
 
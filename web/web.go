@@ -28,9 +28,11 @@ import (
 	"github.com/flexpool/solo/log"
 	"github.com/flexpool/solo/nodeapi"
 	"github.com/flexpool/solo/process"
+	"github.com/flexpool/solo/stats"
 	"github.com/flexpool/solo/utils"
 
 	"github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 // h is a Simple shortcut to map[string]interface{}
@@ -41,9 +43,15 @@ type Server struct {
 	httpServer      *http.Server
 	database        *db.Database
 	workmanager     *gateway.WorkManager
-	node            *nodeapi.Node
+	banManager      *gateway.BanManager
+	connLimiter     *gateway.ConnectionLimiter
+	sessionRegistry *gateway.SessionRegistry
+	credentials     *gateway.CredentialStore
+	statsCollector  *stats.Collector
+	node            *nodeapi.NodePool
 	engineWaitGroup *sync.WaitGroup
 	shuttingDown    bool
+	adminToken      string
 }
 
 // APIResponse is an interface to APIResponse
@@ -53,77 +61,135 @@ type APIResponse struct {
 }
 
 // MarshalAPIResponse function marshals APIResponse struct
-func MarshalAPIResponse(resp APIResponse) []byte {
-	data, _ := json.Marshal(resp)
-	return data
+func MarshalAPIResponse(resp APIResponse) ([]byte, error) {
+	return json.Marshal(resp)
 }
 
 // H is a shortcut for map[string]interface{}
 type H map[string]interface{}
 
 // NewServer creates new Server instance
-func NewServer(db *db.Database, node *nodeapi.Node, engineWaitGroup *sync.WaitGroup, workmanager *gateway.WorkManager, bind string) *Server {
+func NewServer(db *db.Database, node *nodeapi.NodePool, engineWaitGroup *sync.WaitGroup, workmanager *gateway.WorkManager, banManager *gateway.BanManager, connLimiter *gateway.ConnectionLimiter, sessionRegistry *gateway.SessionRegistry, credentials *gateway.CredentialStore, statsCollector *stats.Collector, bind string, adminToken string, corsAllowedOrigins []string) *Server {
 	mux := http.NewServeMux()
 
 	server := Server{
 		database:        db,
 		node:            node,
 		workmanager:     workmanager,
+		banManager:      banManager,
+		connLimiter:     connLimiter,
+		sessionRegistry: sessionRegistry,
+		credentials:     credentials,
+		statsCollector:  statsCollector,
 		engineWaitGroup: engineWaitGroup,
+		adminToken:      adminToken,
 	}
 
 	mux.HandleFunc("/api/v1/currentBlock", func(w http.ResponseWriter, r *http.Request) {
+		currentBlock, err := server.node.BlockNumber()
 
-		// DEBUG ONLY !!!
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		nodesHealth := server.node.Health()
 
-		currentBlock, err := server.node.BlockNumber()
+		status := http.StatusOK
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			status = http.StatusInternalServerError
 		}
-		w.Write(MarshalAPIResponse(APIResponse{
-			Result: currentBlock,
-			Error:  err,
-		}))
+
+		writeAPIResponse(w, status, h{
+			"blockNumber": currentBlock,
+			"syncing":     nodesHealth[0].Syncing,
+			"healthy":     nodesHealth[0].Healthy,
+			"nodes":       nodesHealth,
+		}, processError(err))
 	})
 
 	mux.HandleFunc("/api/v1/stats", func(w http.ResponseWriter, r *http.Request) {
-		// DEBUG ONLY !!!
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if workerName := r.URL.Query().Get("workerName"); workerName != "" {
+			server.handleWorkerStats(w, workerName)
+			return
+		}
+
+		// The stats are missing until the first collection
+		currentTotalStats, err := server.database.GetTotalStatsByTimestamp(server.database.CurrentStatTimestamp())
+		if err != nil && err != leveldb.ErrNotFound {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+			return
+		}
 
-		currentTotalStats, err := server.database.GetTotalStatsByTimestamp(utils.GetCurrent10MinTimestamp())
 		totalShares, err := server.database.GetTotalShares()
+		if err != nil && err != leveldb.ErrNotFound {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+			return
+		}
 
 		averageEffective := db.GetTotalAverageHashrate()
 		averageEffectiveFloat, _ := big.NewFloat(0).SetInt(averageEffective).Float64()
 
 		siDiv, siChar := utils.GetSI(averageEffectiveFloat)
 
-		data, _ := json.Marshal(h{
-			"result": h{
-				"hashrate": h{
-					"effective": currentTotalStats.EffectiveHashrate,
-					"reported":  currentTotalStats.ReportedHashrate,
-					"average":   averageEffective,
-				},
-				"shares": h{
-					"valid":   totalShares.ValidShares,
-					"stale":   totalShares.StaleShares,
-					"invalid": totalShares.InvalidShares,
-				},
-				"si": h{
-					"div":  siDiv,
-					"char": siChar,
-				},
+		writeAPIResponse(w, http.StatusOK, h{
+			"hashrate": h{
+				"effective": currentTotalStats.EffectiveHashrate,
+				"reported":  currentTotalStats.ReportedHashrate,
+				"average":   averageEffective,
+				"live":      server.statsCollector.Hashrate.Hashrate(),
+			},
+			"shares": h{
+				"valid":     totalShares.ValidShares,
+				"stale":     totalShares.StaleShares,
+				"invalid":   totalShares.InvalidShares,
+				"duplicate": totalShares.DuplicateShares,
+			},
+			"si": h{
+				"div":  siDiv,
+				"char": siChar,
+			},
+			"sessions": h{
+				"active":   server.connLimiter.ActiveSessions(),
+				"rejected": server.connLimiter.RejectedSessions(),
 			},
-			"error": processError(err),
-		})
-		w.Write(data)
+			"notifications": h{
+				"rejected": server.workmanager.RejectedNotifications(),
+			},
+		}, nil)
+	})
+
+	mux.HandleFunc("/api/v1/hashrate", func(w http.ResponseWriter, r *http.Request) {
+		workerName := r.URL.Query().Get("workerName")
+		if workerName != "" {
+			writeAPIResponse(w, http.StatusOK, server.statsCollector.Hashrate.WorkerHashrate(workerName), nil)
+			return
+		}
+
+		writeAPIResponse(w, http.StatusOK, h{
+			"total":   server.statsCollector.Hashrate.Hashrate(),
+			"workers": server.statsCollector.Hashrate.WorkersHashrate(),
+		}, nil)
+	})
+
+	mux.HandleFunc("/api/v1/history", server.handleHistory)
+	mux.HandleFunc("/api/v1/workers", server.handleWorkers)
+	mux.HandleFunc("/api/v1/headerStats", server.handleHeaderStats)
+	mux.HandleFunc("/api/v1/coinbaseBalance", server.handleCoinbaseBalance)
+	mux.HandleFunc("/api/v1/blocks", server.handleBlocks)
+	mux.HandleFunc("/api/v1/bestShares", server.handleBestShares)
+
+	mux.HandleFunc("/api/v1/sessions", server.handleSessions)
+
+	mux.HandleFunc("/api/v1/admin/sessions/disconnect", server.handleAdminDisconnectSession)
+	mux.HandleFunc("/api/v1/admin/workers", server.handleAdminWorkers)
+	mux.HandleFunc("/api/v1/admin/workers/enable", server.handleAdminWorkerState(true))
+	mux.HandleFunc("/api/v1/admin/workers/disable", server.handleAdminWorkerState(false))
+	mux.HandleFunc("/api/v1/admin/bans", server.handleAdminBans)
+
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIResponse(w, http.StatusNotFound, nil, "Not found")
 	})
+	mux.Handle("/", dashboardHandler())
 
 	server.httpServer = &http.Server{
 		Addr:    bind,
-		Handler: mux,
+		Handler: corsHandler(corsAllowedOrigins, mux),
 	}
 
 	return &server
@@ -0,0 +1,296 @@
+// Flexpool Solo - A lightweight SOLO Ethereum mining pool
+// Copyright (C) 2020  Flexpool
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/flexpool/solo/db"
+	"github.com/flexpool/solo/log"
+	"github.com/flexpool/solo/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// historyItem is a single stat of the history API
+type historyItem struct {
+	Timestamp         int64   `json:"timestamp"`
+	EffectiveHashrate float64 `json:"effectiveHashrate"`
+	ReportedHashrate  float64 `json:"reportedHashrate"`
+	ValidShares       uint64  `json:"validShares"`
+	StaleShares       uint64  `json:"staleShares"`
+	InvalidShares     uint64  `json:"invalidShares"`
+	DuplicateShares   uint64  `json:"duplicateShares"`
+}
+
+// workerItem is a single worker of the workers API
+type workerItem struct {
+	EffectiveHashrate float64 `json:"effectiveHashrate"`
+	ReportedHashrate  float64 `json:"reportedHashrate"`
+	ValidShares       uint64  `json:"validShares"`
+	StaleShares       uint64  `json:"staleShares"`
+	InvalidShares     uint64  `json:"invalidShares"`
+	DuplicateShares   uint64  `json:"duplicateShares"`
+	LastSeen          int64   `json:"lastSeen"` // Seconds since the worker was last seen
+	Online            bool    `json:"online"`
+}
+
+// historyTimestamp returns the timestamp of the i-th item of the history
+func (a *Server) historyTimestamp(i int, historyLength int) int64 {
+	return a.database.CurrentStatTimestamp() - int64(historyLength-1-i)*a.database.StatsOptions.CollectionPeriodSecs
+}
+
+// handleHistory returns the total history, or the worker's history if the workerName is specified
+func (a *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	var history []historyItem
+
+	workerName := r.URL.Query().Get("workerName")
+	if workerName == "" {
+		totalHistory, err := a.database.GetTotalHistory()
+		if err != nil {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+			return
+		}
+
+		for i, stat := range totalHistory {
+			history = append(history, historyItem{
+				Timestamp:         a.historyTimestamp(i, len(totalHistory)),
+				EffectiveHashrate: stat.EffectiveHashrate,
+				ReportedHashrate:  stat.ReportedHashrate,
+				ValidShares:       stat.ValidShareCount,
+				StaleShares:       stat.StaleShareCount,
+				InvalidShares:     stat.InvalidShareCount,
+				DuplicateShares:   stat.DuplicateShareCount,
+			})
+		}
+	} else {
+		workerHistory, err := a.database.GetWorkerHistory(workerName)
+		if err != nil {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+			return
+		}
+
+		for i, stat := range workerHistory {
+			history = append(history, historyItem{
+				Timestamp:         a.historyTimestamp(i, len(workerHistory)),
+				EffectiveHashrate: stat.EffectiveHashrate,
+				ReportedHashrate:  stat.ReportedHashrate,
+				ValidShares:       stat.ValidShareCount,
+				StaleShares:       stat.StaleShareCount,
+				InvalidShares:     stat.InvalidShareCount,
+				DuplicateShares:   stat.DuplicateShareCount,
+			})
+		}
+	}
+
+	writeAPIResponse(w, http.StatusOK, history, nil)
+}
+
+// workers merges the collected worker stats with the pending ones
+func (a *Server) workers() (map[string]workerItem, error) {
+	summaries, err := a.database.GetWorkerSummaries()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make(map[string]workerItem)
+	lastSeen := make(map[string]int64)
+
+	for workerName, summary := range summaries {
+		workers[workerName] = workerItem{
+			EffectiveHashrate: summary.EffectiveHashrate,
+			ReportedHashrate:  summary.ReportedHashrate,
+			ValidShares:       summary.ValidShareCount,
+			StaleShares:       summary.StaleShareCount,
+			InvalidShares:     summary.InvalidShareCount,
+			DuplicateShares:   summary.DuplicateShareCount,
+		}
+		lastSeen[workerName] = summary.LastSeen
+	}
+
+	// Shares of the current collection period are not collected yet
+	a.statsCollector.Mux.Lock()
+	for workerName, pendingStat := range a.statsCollector.PendingStats {
+		worker := workers[workerName]
+		worker.ValidShares += pendingStat.ValidShares
+		worker.StaleShares += pendingStat.StaleShares
+		worker.InvalidShares += pendingStat.InvalidShares
+		worker.DuplicateShares += pendingStat.DuplicateShares
+		workers[workerName] = worker
+
+		if _, ok := lastSeen[workerName]; !ok {
+			lastSeen[workerName] = a.database.CurrentStatTimestamp()
+		}
+	}
+	a.statsCollector.Mux.Unlock()
+
+	// The live estimator knows the exact time of the last share
+	for workerName, lastShare := range a.statsCollector.Hashrate.WorkersLastShare() {
+		if _, ok := workers[workerName]; ok {
+			lastSeen[workerName] = lastShare.Unix()
+		}
+	}
+
+	// Workers that haven't submitted a share during the whole collection period are offline
+	offlineAfterSecs := a.database.StatsOptions.CollectionPeriodSecs
+
+	now := time.Now().Unix()
+	for workerName, worker := range workers {
+		worker.LastSeen = now - lastSeen[workerName]
+		if worker.LastSeen < 0 {
+			worker.LastSeen = 0
+		}
+		worker.Online = worker.LastSeen <= offlineAfterSecs
+		workers[workerName] = worker
+	}
+
+	return workers, nil
+}
+
+// handleWorkers returns all workers seen within the stats retention
+func (a *Server) handleWorkers(w http.ResponseWriter, r *http.Request) {
+	workers, err := a.workers()
+	if err != nil {
+		writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, workers, nil)
+}
+
+// handleHeaderStats returns the dashboard header stats
+func (a *Server) handleHeaderStats(w http.ResponseWriter, r *http.Request) {
+	workers, err := a.workers()
+	if err != nil {
+		writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+		return
+	}
+
+	var workersOnline, workersOffline uint64
+	for _, worker := range workers {
+		if worker.Online {
+			workersOnline++
+		} else {
+			workersOffline++
+		}
+	}
+
+	var efficiency float64
+	totalShares, err := a.database.GetTotalShares()
+	if err != nil && err != leveldb.ErrNotFound {
+		writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+		return
+	}
+	if sum := totalShares.ValidShares + totalShares.StaleShares + totalShares.InvalidShares; sum != 0 {
+		efficiency = float64(totalShares.ValidShares) / float64(sum) * 100
+	}
+
+	// The node lookups are optional, the failed ones are null so the rest of the header is still shown
+	header := h{
+		"workersOnline":   workersOnline,
+		"workersOffline":  workersOffline,
+		"coinbaseBalance": nil,
+		"efficiency":      efficiency,
+		"chainId":         nil,
+	}
+
+	if coinbaseBalance, err := a.node.CoinbaseBalance(); err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "web",
+			"error":  err,
+		}).Warn("Unable to get coinbase balance")
+	} else {
+		header["coinbaseBalance"] = coinbaseBalance
+	}
+
+	if chainID, err := a.node.ChainID(); err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"prefix": "web",
+			"error":  err,
+		}).Warn("Unable to get chain ID")
+	} else {
+		header["chainId"] = chainID
+	}
+
+	writeAPIResponse(w, http.StatusOK, header, nil)
+}
+
+// handleCoinbaseBalance returns the balance (in wei) of the node's coinbase
+func (a *Server) handleCoinbaseBalance(w http.ResponseWriter, r *http.Request) {
+	coinbaseBalance, err := a.node.CoinbaseBalance()
+	if err != nil {
+		writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, coinbaseBalance, nil)
+}
+
+// handleWorkerStats returns the worker's stats in the same format as the total ones
+func (a *Server) handleWorkerStats(w http.ResponseWriter, workerName string) {
+	currentStats, err := a.database.GetStatsByTimestamp(workerName, a.database.CurrentStatTimestamp())
+	if err != nil && err != leveldb.ErrNotFound {
+		writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+		return
+	}
+
+	history, err := a.database.GetWorkerHistory(workerName)
+	if err != nil {
+		writeAPIResponse(w, http.StatusInternalServerError, nil, processError(err))
+		return
+	}
+
+	var shares db.TotalShares
+	var averageEffective float64
+	var effectiveNoZeroesLen float64
+	for _, stat := range history {
+		shares.ValidShares += stat.ValidShareCount
+		shares.StaleShares += stat.StaleShareCount
+		shares.InvalidShares += stat.InvalidShareCount
+		shares.DuplicateShares += stat.DuplicateShareCount
+		if stat.EffectiveHashrate != 0 {
+			averageEffective += stat.EffectiveHashrate
+			effectiveNoZeroesLen++
+		}
+	}
+	if effectiveNoZeroesLen != 0 {
+		averageEffective /= effectiveNoZeroesLen
+	}
+
+	siDiv, siChar := utils.GetSI(averageEffective)
+
+	writeAPIResponse(w, http.StatusOK, h{
+		"hashrate": h{
+			"effective": currentStats.EffectiveHashrate,
+			"reported":  currentStats.ReportedHashrate,
+			"average":   averageEffective,
+			"live":      a.statsCollector.Hashrate.WorkerHashrate(workerName),
+		},
+		"shares": h{
+			"valid":     shares.ValidShares,
+			"stale":     shares.StaleShares,
+			"invalid":   shares.InvalidShares,
+			"duplicate": shares.DuplicateShares,
+		},
+		"si": h{
+			"div":  siDiv,
+			"char": siChar,
+		},
+	}, nil)
+}